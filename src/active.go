@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	versionEnvVar = "BREWPY_VERSION"
+	// shellShimsEnvVar is the directory `brewpy shell` put on PATH in
+	// symlink mode, so it can be taken off again
+	shellShimsEnvVar = "BREWPY_SHELL_SHIMS"
+)
+
+// selection is a version spec together with where it came from
+type selection struct {
+	Spec   string
+	Origin string // versionEnvVar, the path of a version file, or the state file
+	Global bool   // chosen with `brewpy use` rather than overridden
+}
+
+// findSelection works out which version applies here: $BREWPY_VERSION, then
+// the nearest .python-version above the working directory, then the global
+// selection recorded by `brewpy use`
+func findSelection(config Config) (selection, bool) {
+	if spec := strings.TrimSpace(os.Getenv(versionEnvVar)); spec != "" {
+		return selection{Spec: spec, Origin: versionEnvVar}, true
+	}
+
+	if cwd, err := os.Getwd(); err == nil {
+		if path, specs, ok := findVersionFile(cwd); ok {
+			return selection{Spec: specs[0], Origin: path}, true
+		}
+	}
+
+	if state, ok, _ := loadState(config); ok {
+		return selection{Spec: state.Spec(), Origin: getStatePath(config.BrewPyDir), Global: true}, true
+	}
+
+	return selection{}, false
+}
+
+// findActiveSelection returns the selection the shims follow. Symlink shims
+// can only point at one version, so .python-version only applies in script
+// mode; `brewpy shell` works in both by putting its own shims on PATH.
+func findActiveSelection(config Config) (selection, bool) {
+	if getShimMode(config) == shimModeScript {
+		return findSelection(config)
+	}
+
+	if spec := strings.TrimSpace(os.Getenv(versionEnvVar)); spec != "" && os.Getenv(shellShimsEnvVar) != "" {
+		return selection{Spec: spec, Origin: versionEnvVar}, true
+	}
+
+	if state, ok, _ := loadState(config); ok {
+		return selection{Spec: state.Spec(), Origin: getStatePath(config.BrewPyDir), Global: true}, true
+	}
+	return selection{}, false
+}
+
+// resolveShimTarget finds the executable a shim should run right now
+func resolveShimTarget(config Config, name string) (string, selection, error) {
+	sel, ok := findSelection(config)
+	if !ok {
+		return "", sel, fmt.Errorf("no Python version selected; run 'brewpy use' first")
+	}
+
+	state, hasState, _ := loadState(config)
+	if hasState {
+		// Versioned shims such as python3.11 always run the version they name
+		if target, ok := state.Versioned[name]; ok {
+			return target, sel, nil
+		}
+
+		// The global selection already recorded its targets, so skip discovery
+		if target, ok := state.AllShims()[name]; ok && sel.Global {
+			return target, sel, nil
+		}
+	}
+
+	if hasState {
+		config = withRecordedPrefix(config, state)
+	}
+	versions, err := scanInterpreters(config)
+	if err != nil {
+		return "", sel, err
+	}
+	version, err := resolveVersion(versions, sel.Spec)
+	if err != nil {
+		return "", sel, fmt.Errorf("version set by %s: %w", sel.Origin, err)
+	}
+
+	if target, ok := shimTargets(config, version)[name]; ok {
+		return target, sel, nil
+	}
+
+	// Fall back to a console script installed for that version
+	cache := loadInfoCache(config)
+	info, err := cache.Lookup(version)
+	cache.Save()
+	if err == nil {
+		if target, ok := findEntryPoints(config, version, info)[name]; ok {
+			return target, sel, nil
+		}
+	}
+	return "", sel, fmt.Errorf("%s is not installed for %s", name, version.FullName())
+}
+
+func handleExec() {
+	if len(os.Args) < 3 {
+		fmt.Fprintf(os.Stderr, "%s brewpy exec <command> [args...]\n", red("Usage:"))
+		os.Exit(1)
+	}
+
+	name := os.Args[2]
+	target, _, err := resolveShimTarget(loadConfig(), name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", red("brewpy:"), err)
+		os.Exit(1)
+	}
+
+	// argv[0] is the real path so Python finds its prefix from it, not the shim
+	argv := append([]string{target}, os.Args[3:]...)
+	if err := syscall.Exec(target, argv, os.Environ()); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to run %s: %v\n", red("brewpy:"), target, err)
+		os.Exit(1)
+	}
+}
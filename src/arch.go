@@ -0,0 +1,65 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"runtime"
+)
+
+// binaryArch reads the executable header of path to find the architecture it
+// was built for. Returns an empty string when the file can't be parsed.
+func binaryArch(path string) string {
+	if file, err := macho.Open(path); err == nil {
+		defer file.Close()
+		return machoCPUName(file.Cpu)
+	}
+
+	if fat, err := macho.OpenFat(path); err == nil {
+		defer fat.Close()
+		if len(fat.Arches) > 1 {
+			return "universal"
+		}
+		if len(fat.Arches) == 1 {
+			return machoCPUName(fat.Arches[0].Cpu)
+		}
+	}
+
+	if file, err := elf.Open(path); err == nil {
+		defer file.Close()
+		switch file.Machine {
+		case elf.EM_X86_64:
+			return "x86_64"
+		case elf.EM_AARCH64:
+			return "arm64"
+		}
+	}
+
+	return ""
+}
+
+func machoCPUName(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.CpuArm64:
+		return "arm64"
+	}
+	return cpu.String()
+}
+
+// prefixArch guesses the architecture of a Homebrew prefix from its location,
+// for when the interpreter binary itself can't be inspected
+func prefixArch(prefix string) string {
+	switch prefix {
+	case "/opt/homebrew":
+		return "arm64"
+	case "/usr/local":
+		if runtime.GOOS == "darwin" {
+			return "x86_64"
+		}
+	}
+	if runtime.GOARCH == "amd64" {
+		return "x86_64"
+	}
+	return runtime.GOARCH
+}
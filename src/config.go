@@ -5,14 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/manifoldco/promptui"
 )
 
 type Config struct {
-	ShellRC   string `json:"shell_rc"`
-	BrewPyDir string `json:"brewpy_dir"`
+	ShellRC    string            `json:"shell_rc"`
+	BrewPyDir  string            `json:"brewpy_dir"`
+	Prefix     string            `json:"prefix,omitempty"`
+	Prefixes   []string          `json:"prefixes,omitempty"`
+	Sources    []string          `json:"sources,omitempty"`
+	ShimMode   string            `json:"shim_mode,omitempty"`
+	Shims      map[string]string `json:"shims,omitempty"`
+	LinkTarget string            `json:"link_target,omitempty"`
+	Profiles   []string          `json:"profiles,omitempty"`
 }
 
 // getDefaultBrewPyDir returns the default BrewPy directory
@@ -26,6 +34,12 @@ func getShimsDir(brewPyDir string) string {
 	return filepath.Join(brewPyDir, "shims")
 }
 
+// getShimsStoreDir returns the directory holding shim generations; the shims
+// directory is a symlink to one of them
+func getShimsStoreDir(brewPyDir string) string {
+	return filepath.Join(brewPyDir, "shims.d")
+}
+
 // getConfigPath returns the path to the config file based on BrewPyDir
 func getConfigPath(brewPyDir string) string {
 	return filepath.Join(brewPyDir, "config.json")
@@ -60,21 +74,22 @@ func getDefaultConfig() Config {
 	}
 }
 
+// detectShellRC returns the rc file of the user's shell; see detectShellSetup
 func detectShellRC() string {
-	homeDir, _ := os.UserHomeDir()
-	
-	// Check for common shell RC files in order of preference
-	rcFiles := []string{".zshrc", ".bashrc", ".config/fish/config.fish"}
-	
-	for _, rcFile := range rcFiles {
-		fullPath := filepath.Join(homeDir, rcFile)
-		if _, err := os.Stat(fullPath); err == nil {
-			return fullPath
+	return detectShellSetup().RC
+}
+
+// getProfiles returns every rc file the init block is kept in: ShellRC plus
+// the extra profiles, e.g. a login file next to an interactive one
+func getProfiles(config Config) []string {
+	profiles := []string{expandPath(config.ShellRC)}
+	for _, profile := range config.Profiles {
+		profile = expandPath(profile)
+		if !slices.Contains(profiles, profile) {
+			profiles = append(profiles, profile)
 		}
 	}
-	
-	// Default to .zshrc if none found
-	return filepath.Join(homeDir, ".zshrc")
+	return profiles
 }
 
 // initConfig creates the BrewPy directory and initializes config if needed
@@ -85,8 +100,7 @@ func initConfig(config Config) error {
 	}
 	
 	// Create shims directory
-	shimsDir := getShimsDir(config.BrewPyDir)
-	if err := os.MkdirAll(shimsDir, 0755); err != nil {
+	if err := ensureShimsDir(config); err != nil {
 		return fmt.Errorf("failed to create shims directory: %w", err)
 	}
 	
@@ -95,16 +109,16 @@ func initConfig(config Config) error {
 }
 
 func loadConfig() Config {
-	// Start with default config
-	config := getDefaultConfig()
-	
-	// Try to find existing config file
-	configPath, exists := findConfigFile(config.BrewPyDir)
+	// Try to find existing config file. Shell detection can run ps, and
+	// script shims load the config on every call, so the defaults are only
+	// worked out when there's no usable file.
+	configPath, exists := findConfigFile(getDefaultBrewPyDir())
 	
 	if !exists {
 		// No config file found, initialize with defaults
+		config := getDefaultConfig()
 		if err := initConfig(config); err != nil {
-			fmt.Printf("%s Failed to initialize config: %v\n", yellow("Warning:"), err)
+			fmt.Fprintf(os.Stderr, "%s Failed to initialize config: %v\n", yellow("Warning:"), err)
 		}
 		return config
 	}
@@ -112,21 +126,24 @@ func loadConfig() Config {
 	// Read existing config file
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		fmt.Printf("%s Failed to read config file, using defaults: %v\n", yellow("Warning:"), err)
-		return config
+		fmt.Fprintf(os.Stderr, "%s Failed to read config file, using defaults: %v\n", yellow("Warning:"), err)
+		return getDefaultConfig()
 	}
 	
 	// Parse JSON
 	var loadedConfig Config
 	if err := json.Unmarshal(data, &loadedConfig); err != nil {
-		fmt.Printf("%s Failed to parse config file, using defaults: %v\n", yellow("Warning:"), err)
-		return config
+		fmt.Fprintf(os.Stderr, "%s Failed to parse config file, using defaults: %v\n", yellow("Warning:"), err)
+		return getDefaultConfig()
+	}
+	if loadedConfig.ShellRC == "" {
+		loadedConfig.ShellRC = detectShellRC()
 	}
 	
 	// Migrate config if BrewPyDir has changed and we loaded from default location
 	if configPath == getConfigPath(getDefaultBrewPyDir()) && loadedConfig.BrewPyDir != getDefaultBrewPyDir() {
 		if err := migrateConfig(loadedConfig, configPath); err != nil {
-			fmt.Printf("%s Failed to migrate config: %v\n", yellow("Warning:"), err)
+			fmt.Fprintf(os.Stderr, "%s Failed to migrate config: %v\n", yellow("Warning:"), err)
 		}
 	}
 	
@@ -182,6 +199,9 @@ func handleConfigure() {
 	fmt.Printf("  BrewPy directory: %s\n", blue(config.BrewPyDir))
 	fmt.Printf("  Shims directory:  %s\n", blue(getShimsDir(config.BrewPyDir)))
 	fmt.Printf("  Shell RC file:    %s\n", blue(config.ShellRC))
+	for _, profile := range getProfiles(config)[1:] {
+		fmt.Printf("  Also kept in:     %s\n", blue(profile))
+	}
 	fmt.Printf("\n")
 	
 	// Ask what to configure
@@ -204,6 +224,12 @@ func handleConfigure() {
 			return
 		}
 		
+	case "profiles":
+		if err := configureProfiles(&config); err != nil {
+			fmt.Printf("%s %v\n", red("Error:"), err)
+			return
+		}
+		
 	case "all":
 		if err := configureAll(&config); err != nil {
 			fmt.Printf("%s %v\n", red("Error:"), err)
@@ -240,6 +266,9 @@ func handleConfigure() {
 	fmt.Printf("  BrewPy directory: %s\n", config.BrewPyDir)
 	fmt.Printf("  Shims directory:  %s\n", getShimsDir(config.BrewPyDir))
 	fmt.Printf("  Shell RC file:    %s\n", config.ShellRC)
+	for _, profile := range getProfiles(config)[1:] {
+		fmt.Printf("  Also kept in:     %s\n", profile)
+	}
 	
 	fmt.Printf("\n%s Run 'brewpy use' to apply changes to your Python setup.\n", yellow("Note:"))
 }
@@ -266,17 +295,30 @@ func configureShellRC(config *Config) error {
 	return nil
 }
 
+func configureProfiles(config *Config) error {
+	profiles, err := promptProfiles(config.Profiles)
+	if err != nil {
+		return err
+	}
+	config.Profiles = profiles
+	return nil
+}
+
 func configureAll(config *Config) error {
 	if err := configureBrewPyDirectory(config); err != nil {
 		return err
 	}
-	return configureShellRC(config)
+	if err := configureShellRC(config); err != nil {
+		return err
+	}
+	return configureProfiles(config)
 }
 
 func promptConfigChoice() (string, error) {
 	items := []string{
 		"BrewPy directory (where config and shims are stored)",
 		"Shell RC file (where 'brewpy init' will be added)",
+		"Additional rc files (kept in sync with the shell RC file)",
 		"Configure all settings",
 		"Reset to defaults",
 		"Cancel",
@@ -291,7 +333,7 @@ func promptConfigChoice() (string, error) {
 			Inactive: "  {{ . }}",
 			Selected: fmt.Sprintf("%s {{ . | green }}", "✓"),
 		},
-		Size: 5,
+		Size: 6,
 	}
 	
 	index, _, err := prompt.Run()
@@ -299,7 +341,7 @@ func promptConfigChoice() (string, error) {
 		return "", err
 	}
 	
-	choices := []string{"brewpy_dir", "shell_rc", "all", "reset", "cancel"}
+	choices := []string{"brewpy_dir", "shell_rc", "profiles", "all", "reset", "cancel"}
 	return choices[index], nil
 }
 
@@ -348,6 +390,17 @@ func promptShellRC(currentValue string) (string, error) {
 		{"Enter custom path", "", false},
 	}
 	
+	// Offer the detected rc file first. Detection can run ps, so it only runs once.
+	detected := detectShellRC()
+	if detected != currentValue {
+		_, err := os.Stat(detected)
+		options = append(options, shellRCOption{
+			Name:   "Detected: " + detected,
+			Path:   detected,
+			Exists: err == nil,
+		})
+	}
+	
 	// Add common shell RC files
 	rcFiles := []string{".zshrc", ".bashrc", ".bash_profile", ".profile", ".dashrc", ".config/fish/config.fish"}
 	for _, rcFile := range rcFiles {
@@ -357,8 +410,8 @@ func promptShellRC(currentValue string) (string, error) {
 			exists = true
 		}
 		
-		// Don't add if it's already the current value
-		if fullPath != currentValue {
+		// Don't add if it's already the current or detected value
+		if fullPath != currentValue && fullPath != detected {
 			options = append(options, shellRCOption{
 				Name:   rcFile,
 				Path:   fullPath,
@@ -440,6 +493,48 @@ func promptShellRC(currentValue string) (string, error) {
 	return selected.Path, nil
 }
 
+func promptProfiles(current []string) ([]string, error) {
+	validate := func(input string) error {
+		for _, path := range strings.Split(input, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			parentDir := filepath.Dir(expandPath(path))
+			if _, err := os.Stat(parentDir); os.IsNotExist(err) {
+				return fmt.Errorf("parent directory %s does not exist", parentDir)
+			}
+		}
+		return nil
+	}
+	
+	prompt := promptui.Prompt{
+		Label:     "🐚 Additional rc files, comma separated (e.g. ~/.bash_profile, ~/.zprofile)",
+		Default:   strings.Join(current, ", "),
+		AllowEdit: true,
+		Validate:  validate,
+		Templates: &promptui.PromptTemplates{
+			Prompt:  "{{ . | cyan }}: ",
+			Valid:   "{{ . | cyan }}: ",
+			Invalid: "{{ . | red }}: ",
+			Success: "{{ . | green }}: ",
+		},
+	}
+	
+	result, err := prompt.Run()
+	if err != nil {
+		return nil, err
+	}
+	
+	var profiles []string
+	for _, path := range strings.Split(result, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			profiles = append(profiles, expandPath(path))
+		}
+	}
+	return profiles, nil
+}
+
 func promptConfirmReset() (bool, error) {
 	prompt := promptui.Prompt{
 		Label:     "⚠️  Reset all settings to defaults? (y/N)",
@@ -472,6 +567,27 @@ func handleConfigShow() {
 	fmt.Printf("BrewPy directory: %s\n", config.BrewPyDir)
 	fmt.Printf("Shims directory:  %s\n", getShimsDir(config.BrewPyDir))
 	fmt.Printf("Shell RC file:    %s\n", config.ShellRC)
+	displayShellDetection(config, detectShellSetup())
+	for _, profile := range getProfiles(config)[1:] {
+		fmt.Printf("Also kept in:     %s\n", profile)
+	}
+	
+	prefixes := getBrewPrefixes(config)
+	for i, prefix := range prefixes {
+		label := "Homebrew prefix: "
+		if i > 0 {
+			label = "                 "
+		}
+		fmt.Printf("%s %s (from %s)\n", label, prefix.Path, prefix.Source)
+	}
+	
+	sourceNames := []string{}
+	for _, source := range getSources(config) {
+		sourceNames = append(sourceNames, source.Name())
+	}
+	fmt.Printf("Sources:          %s\n", strings.Join(sourceNames, ", "))
+	fmt.Printf("Shim mode:        %s\n", getShimMode(config))
+	fmt.Printf("Link target:      %s\n", getLinkTarget(config))
 	
 	fmt.Printf("\n%s Status:\n", bold("📊"))
 	
@@ -489,9 +605,19 @@ func handleConfigShow() {
 		fmt.Printf("  %s Shims directory exists\n", green("✓"))
 	}
 	
-	if _, err := os.Stat(config.ShellRC); os.IsNotExist(err) {
-		fmt.Printf("  %s Shell RC file does not exist\n", yellow("⚠"))
-	} else {
-		fmt.Printf("  %s Shell RC file exists\n", green("✓"))
+	for _, profile := range getProfiles(config) {
+		if ok, status := profileStatus(config, profile); ok {
+			fmt.Printf("  %s %s %s\n", green("✓"), profile, status)
+		} else {
+			fmt.Printf("  %s %s %s\n", yellow("⚠"), profile, status)
+		}
+	}
+	
+	for _, prefix := range prefixes {
+		if isBrewPrefix(prefix.Path) {
+			fmt.Printf("  %s Homebrew prefix %s contains bin/brew\n", green("✓"), prefix.Path)
+		} else {
+			fmt.Printf("  %s Homebrew prefix %s does not contain bin/brew\n", yellow("⚠"), prefix.Path)
+		}
 	}
 } 
\ No newline at end of file
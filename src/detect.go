@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// shellDetection records which shell and rc file BrewPy picked, and why
+type shellDetection struct {
+	Shell    shellSyntax // nil when no shell could be identified
+	ShellWhy string      // how the shell was found, e.g. "$SHELL is /bin/zsh"
+	RC       string
+	RCWhy    string // why this rc file is the one the shell reads
+}
+
+// detectShell works out which shell `brewpy init` is printing code for when
+// no shell is named. The parent process is the shell that will eval the
+// output, so it's trusted over $SHELL, which is only the login shell.
+func detectShell() shellSyntax {
+	if sh, _, ok := shellFromParent(); ok {
+		return sh
+	}
+	if sh, _, ok := shellFromEnv(); ok {
+		return sh
+	}
+	return shells["sh"]
+}
+
+// detectShellSetup picks the rc file to add the init block to. The login
+// shell in $SHELL is the user's choice of shell, so it comes before the
+// shell BrewPy happens to be running under.
+func detectShellSetup() shellDetection {
+	var detection shellDetection
+	if sh, why, ok := shellFromEnv(); ok {
+		detection.Shell, detection.ShellWhy = sh, why
+	} else if sh, why, ok := shellFromParent(); ok {
+		detection.Shell, detection.ShellWhy = sh, why
+	}
+
+	if detection.Shell != nil {
+		detection.RC, detection.RCWhy = rcForShell(detection.Shell)
+		return detection
+	}
+
+	// Without a known shell, fall back to whichever rc file exists
+	homeDir, _ := os.UserHomeDir()
+	detection.ShellWhy = "neither $SHELL nor the parent process is a supported shell"
+	for _, rcFile := range []string{".zshrc", ".bashrc", ".config/fish/config.fish"} {
+		fullPath := filepath.Join(homeDir, rcFile)
+		if _, err := os.Stat(fullPath); err == nil {
+			detection.RC = fullPath
+			detection.RCWhy = "it's the first common rc file that exists"
+			return detection
+		}
+	}
+	detection.RC = filepath.Join(homeDir, zshrcPath)
+	detection.RCWhy = "no common rc file exists, so the zsh default is used"
+	return detection
+}
+
+// shellFromEnv identifies the login shell from $SHELL
+func shellFromEnv() (shellSyntax, string, bool) {
+	path := os.Getenv("SHELL")
+	if sh, ok := lookupShell(path); ok && path != "" {
+		return sh, fmt.Sprintf("$SHELL is %s", path), true
+	}
+	return nil, "", false
+}
+
+// shellFromParent identifies the shell BrewPy was started from
+func shellFromParent() (shellSyntax, string, bool) {
+	name := parentProcessName()
+	if sh, ok := lookupShell(name); ok && name != "" {
+		return sh, fmt.Sprintf("the parent process is %s", name), true
+	}
+	return nil, "", false
+}
+
+// parentProcessName returns the command name of the parent process, or an
+// empty string if it can't be found
+func parentProcessName() string {
+	ppid := strconv.Itoa(os.Getppid())
+
+	if comm, err := os.ReadFile(filepath.Join("/proc", ppid, "comm")); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+
+	// macOS has no /proc
+	out, err := exec.Command("ps", "-o", "comm=", "-p", ppid).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// rcForShell returns the rc file a shell reads at startup, following the
+// shell's own conventions for where its config lives
+func rcForShell(sh shellSyntax) (string, string) {
+	homeDir, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	configHomeWhy := "$XDG_CONFIG_HOME"
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+		configHomeWhy = "~/.config"
+	}
+
+	switch sh.Name() {
+	case "zsh":
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			return filepath.Join(zdotdir, ".zshrc"), "zsh reads .zshrc from $ZDOTDIR in every interactive shell"
+		}
+		return filepath.Join(homeDir, ".zshrc"), "zsh reads ~/.zshrc in every interactive shell"
+
+	case "bash":
+		if runtime.GOOS != "darwin" {
+			return filepath.Join(homeDir, ".bashrc"), "bash reads ~/.bashrc in interactive non-login shells, which terminals start on Linux"
+		}
+		// macOS terminals start login shells, which read the first of these
+		for _, name := range []string{".bash_profile", ".bash_login", ".profile"} {
+			path := filepath.Join(homeDir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, fmt.Sprintf("terminals on macOS start bash as a login shell, which reads ~/%s first", name)
+			}
+		}
+		return filepath.Join(homeDir, ".bash_profile"), "terminals on macOS start bash as a login shell, which reads ~/.bash_profile"
+
+	case "fish":
+		return filepath.Join(configHome, "fish", "config.fish"), fmt.Sprintf("fish reads config.fish from %s/fish", configHomeWhy)
+
+	case "nu":
+		// nushell only follows XDG on macOS when it's set explicitly
+		if os.Getenv("XDG_CONFIG_HOME") == "" && runtime.GOOS == "darwin" {
+			return filepath.Join(homeDir, "Library", "Application Support", "nushell", "config.nu"), "nushell reads config.nu from ~/Library/Application Support/nushell on macOS"
+		}
+		return filepath.Join(configHome, "nushell", "config.nu"), fmt.Sprintf("nushell reads config.nu from %s/nushell", configHomeWhy)
+
+	case "pwsh":
+		return filepath.Join(configHome, "powershell", "Microsoft.PowerShell_profile.ps1"), fmt.Sprintf("PowerShell reads its current user profile from %s/powershell", configHomeWhy)
+
+	case "xonsh":
+		legacy := filepath.Join(homeDir, ".xonshrc")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, "xonsh reads ~/.xonshrc, which already exists"
+		}
+		return filepath.Join(configHome, "xonsh", "rc.xsh"), fmt.Sprintf("xonsh reads rc.xsh from %s/xonsh", configHomeWhy)
+
+	case "elvish":
+		legacy := filepath.Join(homeDir, ".elvish", "rc.elv")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, "elvish reads the legacy ~/.elvish/rc.elv, which already exists"
+		}
+		return filepath.Join(configHome, "elvish", "rc.elv"), fmt.Sprintf("elvish reads rc.elv from %s/elvish", configHomeWhy)
+	}
+
+	return filepath.Join(homeDir, ".profile"), "POSIX shells read ~/.profile at login"
+}
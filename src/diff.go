@@ -0,0 +1,100 @@
+package main
+
+import "fmt"
+
+// diffOp is one line of a line-by-line diff
+type diffOp struct {
+	Kind byte // ' ' for unchanged, '-' for removed, '+' for added
+	Text string
+	A, B int // index of the line in the old and new text at this point
+}
+
+// diffLines compares two texts line by line using their longest common
+// subsequence. rc files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff formats the changes between a and b as unified diff hunks with
+// the given number of context lines
+func unifiedDiff(a, b []string, context int) []string {
+	ops := diffLines(a, b)
+
+	var out []string
+	for lo := 0; lo < len(ops); {
+		// Find the next change and extend the hunk while changes are close
+		first := lo
+		for first < len(ops) && ops[first].Kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for k := first; k < len(ops) && k <= last+2*context; k++ {
+			if ops[k].Kind != ' ' {
+				last = k
+			}
+		}
+
+		start := max(first-context, lo)
+		end := min(last+context+1, len(ops))
+
+		aCount, bCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.Kind != '+' {
+				aCount++
+			}
+			if op.Kind != '-' {
+				bCount++
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%s +%s @@", hunkRange(ops[start].A, aCount), hunkRange(ops[start].B, bCount)))
+		for _, op := range ops[start:end] {
+			out = append(out, string(op.Kind)+op.Text)
+		}
+
+		lo = end
+	}
+	return out
+}
+
+// hunkRange formats one side of a hunk header from the index of its first
+// line. An empty side names the line before it, so adding lines to an empty
+// file is "-0,0".
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		context int
+		want    []string
+	}{
+		{
+			name: "identical",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+		},
+		{
+			name:    "into an empty file",
+			b:       []string{"a", "b"},
+			context: 3,
+			want:    []string{"@@ -0,0 +1,2 @@", "+a", "+b"},
+		},
+		{
+			name:    "everything removed",
+			a:       []string{"a", "b"},
+			context: 3,
+			want:    []string{"@@ -1,2 +0,0 @@", "-a", "-b"},
+		},
+		{
+			name:    "insertion without context",
+			a:       []string{"a", "b", "c"},
+			b:       []string{"a", "b", "x", "c"},
+			context: 0,
+			want:    []string{"@@ -2,0 +3,1 @@", "+x"},
+		},
+		{
+			name:    "change with context",
+			a:       []string{"1", "2", "3", "4", "5", "6", "7"},
+			b:       []string{"1", "2", "3", "x", "5", "6", "7"},
+			context: 1,
+			want:    []string{"@@ -3,3 +3,3 @@", " 3", "-4", "+x", " 5"},
+		},
+		{
+			name:    "distant changes make separate hunks",
+			a:       []string{"a", "1", "2", "3", "4", "5", "b"},
+			b:       []string{"A", "1", "2", "3", "4", "5", "B"},
+			context: 1,
+			want: []string{
+				"@@ -1,2 +1,2 @@", "-a", "+A", " 1",
+				"@@ -6,2 +6,2 @@", " 5", "-b", "+B",
+			},
+		},
+		{
+			name:    "close changes share a hunk",
+			a:       []string{"a", "1", "b"},
+			b:       []string{"A", "1", "B"},
+			context: 1,
+			want:    []string{"@@ -1,3 +1,3 @@", "-a", "+A", " 1", "-b", "+B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff(tt.a, tt.b, tt.context)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
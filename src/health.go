@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Severities for health problems. Errors make an interpreter unusable,
+// warnings mean part of it is missing.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// healthProblem is something wrong with an installed interpreter
+type healthProblem struct {
+	Severity string
+	Message  string
+}
+
+// checkHealth looks for the ways a Homebrew Python install breaks after
+// `brew cleanup` or a half-finished upgrade: dangling or non-executable
+// binaries, a missing pip, and a stdlib without venv or ensurepip
+func checkHealth(interp Interpreter, cache *infoCache) []healthProblem {
+	var problems []healthProblem
+
+	python := interp.Executable()
+	if problem := checkExecutable(python); problem != "" {
+		// Nothing else can be checked without a working interpreter
+		return append(problems, healthProblem{severityError, problem})
+	}
+
+	pip := interp.Binary("pip" + interp.MinorVersion())
+	if problem := checkExecutable(pip); problem != "" {
+		problems = append(problems, healthProblem{severityWarning, problem})
+	}
+
+	info, err := cache.Lookup(interp)
+	if err != nil {
+		return append(problems, healthProblem{severityError, err.Error()})
+	}
+
+	for _, module := range []string{"venv", "ensurepip"} {
+		if _, err := os.Stat(filepath.Join(info.Paths["stdlib"], module, "__init__.py")); err != nil {
+			problems = append(problems, healthProblem{severityWarning, fmt.Sprintf("%s module is missing from %s", module, info.Paths["stdlib"])})
+		}
+	}
+
+	return problems
+}
+
+// checkExecutable describes what's wrong with the executable at path, or
+// returns an empty string if it's fine
+func checkExecutable(path string) string {
+	linkInfo, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Sprintf("%s is missing", filepath.Base(path))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if linkInfo.Mode()&os.ModeSymlink != 0 {
+			target, _ := os.Readlink(path)
+			return fmt.Sprintf("%s is a dangling link to %s", filepath.Base(path), target)
+		}
+		return fmt.Sprintf("%s can't be read: %v", filepath.Base(path), err)
+	}
+
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Sprintf("%s is not executable", filepath.Base(path))
+	}
+
+	return ""
+}
+
+// hasErrors reports whether any problem makes the interpreter unusable
+func hasErrors(problems []healthProblem) bool {
+	for _, problem := range problems {
+		if problem.Severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// reinstallHint says how to reinstall a broken interpreter, which depends on
+// where it came from
+func reinstallHint(interp Interpreter) string {
+	switch interp.Source {
+	case sourceUV:
+		return fmt.Sprintf("reinstall it with 'uv python install --reinstall %s'", interp.Version)
+	case sourcePythonOrg:
+		return "reinstall it with the installer from python.org"
+	case sourceSystem:
+		if runtime.GOOS == "darwin" {
+			return "reinstall the Command Line Tools with 'xcode-select --install'"
+		}
+		return "reinstall it with your system's package manager"
+	}
+
+	formula := "python@" + interp.MinorVersion()
+	if interp.Keg != "" {
+		formula = filepath.Base(filepath.Dir(interp.Keg))
+	}
+	return fmt.Sprintf("reinstall it with 'brew reinstall %s'", formula)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// maxHistory is how many switches the history file keeps
+const maxHistory = 100
+
+// regex to match a history reference such as "@{2}"
+var historyRefRe = regexp.MustCompile(`^@\{(\d+)\}$`)
+
+// historyEntry records one successful `brewpy use`
+type historyEntry struct {
+	selectedVersion
+	Secondary  []selectedVersion `json:"secondary,omitempty"`
+	SwitchedAt time.Time         `json:"switched_at"`
+}
+
+// getHistoryPath returns the path to the history file based on BrewPyDir
+func getHistoryPath(brewPyDir string) string {
+	return filepath.Join(brewPyDir, "history.json")
+}
+
+// loadHistory reads the switch history, oldest first
+func loadHistory(config Config) ([]historyEntry, error) {
+	data, err := os.ReadFile(getHistoryPath(config.BrewPyDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var history []historyEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse history file: %w", err)
+	}
+	return history, nil
+}
+
+func saveHistory(config Config, history []historyEntry) error {
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	if err := writeFileAtomic(getHistoryPath(config.BrewPyDir), data, 0644, nil); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// recordSwitch appends a switch to the history. previous is the state before
+// the switch; when the history is still empty it's recorded first so that
+// `brewpy use -` works straight after upgrading.
+func recordSwitch(config Config, previous State, hadPrevious bool, state State) error {
+	history, err := loadHistory(config)
+	if err != nil {
+		return err
+	}
+
+	if len(history) == 0 && hadPrevious {
+		history = append(history, historyEntryFromState(previous))
+	}
+	history = append(history, historyEntryFromState(state))
+	return saveHistory(config, history)
+}
+
+func historyEntryFromState(state State) historyEntry {
+	return historyEntry{
+		selectedVersion: state.selectedVersion,
+		Secondary:       state.Secondary,
+		SwitchedAt:      state.SelectedAt,
+	}
+}
+
+// isHistoryRef reports whether spec refers to the history rather than a version
+func isHistoryRef(spec string) bool {
+	return spec == "-" || historyRefRe.MatchString(spec)
+}
+
+// resolveHistoryRef finds the interpreters selected by a past switch, primary
+// first. "@{0}" is the most recent switch, "@{1}" the one before it, and "-"
+// is short for "@{1}".
+func resolveHistoryRef(config Config, versions []Interpreter, spec string) ([]Interpreter, error) {
+	n := 1
+	if m := historyRefRe.FindStringSubmatch(spec); m != nil {
+		n, _ = strconv.Atoi(m[1])
+	}
+
+	history, err := loadHistory(config)
+	if err != nil {
+		return nil, err
+	}
+	if n >= len(history) {
+		if n == 1 {
+			return nil, fmt.Errorf("no previous version in the history")
+		}
+		return nil, fmt.Errorf("the history only has %d entries", len(history))
+	}
+
+	entry := history[len(history)-1-n]
+	var selected []Interpreter
+	for _, s := range append([]selectedVersion{entry.selectedVersion}, entry.Secondary...) {
+		// Homebrew replaces a keg on every patch upgrade, so fall back to
+		// whatever the same series has become
+		version, ok := s.resolve(versions)
+		if !ok {
+			return nil, fmt.Errorf("%s from the history is no longer installed", s.Label())
+		}
+		if version.FullName() != s.Version {
+			fmt.Printf("%s %s from the history is no longer installed; using %s instead\n", yellow("Note:"), s.Version, version.FullName())
+		}
+		selected = append(selected, version)
+	}
+	return selected, nil
+}
+
+func handleHistory() {
+	history, err := loadHistory(loadConfig())
+	if err != nil {
+		log.Fatal(red("Error reading history: "), err)
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("%s\n", yellow("No switches recorded yet. Use 'brewpy use' to select a version."))
+		return
+	}
+	displayHistory(history)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InterpreterInfo is what an interpreter reports about itself
+type InterpreterInfo struct {
+	Version           string            `json:"version"`
+	Implementation    string            `json:"implementation"`
+	Platform          string            `json:"platform"`
+	Paths             map[string]string `json:"paths"`
+	UserScripts       string            `json:"user_scripts"`
+	ExternallyManaged bool              `json:"externally_managed"`
+}
+
+// infoCacheEntry is a cached InterpreterInfo, valid while the binary's
+// modification time is unchanged
+type infoCacheEntry struct {
+	ModTime int64           `json:"mtime"`
+	Info    InterpreterInfo `json:"info"`
+	// Error is only set in caches from older versions, which stored failures
+	Error string `json:"error,omitempty"`
+}
+
+// infoCache maps resolved interpreter paths to their metadata
+type infoCache struct {
+	path    string
+	entries map[string]infoCacheEntry
+	dirty   bool
+}
+
+const introspectTimeout = 10 * time.Second
+
+// introspectScript prints the interpreter's metadata as JSON. It has to run
+// on every Python 3 version Homebrew has shipped, so it sticks to old APIs.
+const introspectScript = `
+import json, os, sys, sysconfig
+paths = sysconfig.get_paths()
+user_scripts = ""
+for scheme in ("osx_framework_user", os.name + "_user"):
+    if scheme in sysconfig.get_scheme_names():
+        try:
+            user_scripts = sysconfig.get_paths(scheme)["scripts"]
+            break
+        except Exception:
+            pass
+print(json.dumps({
+    "version": sys.version.split()[0],
+    "implementation": sys.implementation.name,
+    "platform": sysconfig.get_platform(),
+    "paths": {k: paths.get(k, "") for k in ("stdlib", "purelib", "platlib", "scripts", "include", "data")},
+    "user_scripts": user_scripts,
+    "externally_managed": os.path.exists(os.path.join(paths["stdlib"], "EXTERNALLY-MANAGED")),
+}))
+`
+
+// getInfoCachePath returns the interpreter metadata cache path based on BrewPyDir
+func getInfoCachePath(brewPyDir string) string {
+	return filepath.Join(brewPyDir, "interpreters.json")
+}
+
+// loadInfoCache reads the metadata cache, starting empty if it's missing or
+// unreadable
+func loadInfoCache(config Config) *infoCache {
+	cache := &infoCache{
+		path:    getInfoCachePath(config.BrewPyDir),
+		entries: map[string]infoCacheEntry{},
+	}
+
+	data, err := os.ReadFile(cache.path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		cache.entries = map[string]infoCacheEntry{}
+	}
+	return cache
+}
+
+// Lookup returns the metadata for an interpreter, running it only when the
+// cache has no entry for its current binary
+func (c *infoCache) Lookup(interp Interpreter) (InterpreterInfo, error) {
+	resolved, err := filepath.EvalSymlinks(interp.Executable())
+	if err != nil {
+		return InterpreterInfo{}, err
+	}
+	stat, err := os.Stat(resolved)
+	if err != nil {
+		return InterpreterInfo{}, err
+	}
+
+	if entry, ok := c.entries[resolved]; ok && entry.ModTime == stat.ModTime().UnixNano() && entry.Error == "" {
+		return entry.Info, nil
+	}
+
+	// Failures aren't cached: a timeout on a slow first launch or a Ctrl-C
+	// would otherwise mark the interpreter broken until its binary changes
+	info, err := introspect(resolved)
+	if err != nil {
+		if _, ok := c.entries[resolved]; ok {
+			delete(c.entries, resolved)
+			c.dirty = true
+		}
+		return info, err
+	}
+
+	c.entries[resolved] = infoCacheEntry{ModTime: stat.ModTime().UnixNano(), Info: info}
+	c.dirty = true
+	return info, nil
+}
+
+// Save writes the cache back if anything changed, dropping entries for
+// binaries that no longer exist
+func (c *infoCache) Save() error {
+	for path := range c.entries {
+		if _, err := os.Stat(path); err != nil {
+			delete(c.entries, path)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interpreter cache: %w", err)
+	}
+	if err := writeFileAtomic(c.path, data, 0644, nil); err != nil {
+		return fmt.Errorf("failed to write interpreter cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// introspect runs the interpreter at path once to collect its metadata
+func introspect(path string) (InterpreterInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), introspectTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "-I", "-c", introspectScript)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return InterpreterInfo{}, fmt.Errorf("failed to run %s: %s", path, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return InterpreterInfo{}, fmt.Errorf("failed to run %s: %w", path, err)
+	}
+
+	var info InterpreterInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return InterpreterInfo{}, fmt.Errorf("failed to parse output of %s: %w", path, err)
+	}
+	return info, nil
+}
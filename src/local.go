@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const versionFileName = ".python-version"
+
+// findVersionFile walks up from dir looking for a .python-version file and
+// returns its path and the versions it names
+func findVersionFile(dir string) (string, []string, bool) {
+	for {
+		path := filepath.Join(dir, versionFileName)
+		if specs, err := readVersionFile(path); err == nil && len(specs) > 0 {
+			return path, specs, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil, false
+		}
+		dir = parent
+	}
+}
+
+// readVersionFile reads a .python-version file the way pyenv does: versions
+// are separated by whitespace or newlines and "#" starts a comment line
+func readVersionFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		specs = append(specs, strings.Fields(line)...)
+	}
+	return specs, scanner.Err()
+}
+
+// writeVersionFile writes a .python-version file pyenv can read too
+func writeVersionFile(path string, specs []string) error {
+	content := strings.Join(specs, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// versionFileSpec turns a spec into what goes in .python-version. Plain
+// versions are kept as typed so "3.12" follows patch upgrades; keywords and
+// ranges are pinned to the minor version they resolve to, which pyenv also
+// understands. pyenv has no "@" qualifiers, so they're dropped, and
+// "system" is kept for pyenv too.
+func versionFileSpec(spec string, resolved Interpreter) string {
+	if spec == "system" {
+		return spec
+	}
+	name, _, _ := strings.Cut(spec, "@")
+	if m := revisionRe.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	}
+	if version, err := ParseVersion(name); err == nil {
+		return version.String()
+	}
+	return resolved.MinorVersion()
+}
+
+func handleLocal() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(red("Error getting working directory: "), err)
+	}
+	path := filepath.Join(cwd, versionFileName)
+
+	// Without arguments, show the version file that applies here
+	if len(os.Args) < 3 {
+		found, specs, ok := findVersionFile(cwd)
+		if !ok {
+			fmt.Printf("%s\n", yellow("No .python-version file found in this directory or its parents"))
+			return
+		}
+		fmt.Printf("%s %s\n", green(strings.Join(specs, " ")), "(set by "+found+")")
+		return
+	}
+
+	if os.Args[2] == "--unset" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Fatal(red("Error removing .python-version: "), err)
+		}
+		fmt.Printf("%s %s\n", green("✓ Removed"), path)
+		return
+	}
+
+	config := loadConfig()
+	versions, err := findPythonVersions()
+	if err != nil {
+		log.Fatal(red("Error finding Python versions: "), err)
+	}
+
+	var specs []string
+	for _, spec := range os.Args[2:] {
+		version, err := resolveVersion(versions, spec)
+		if err != nil {
+			log.Fatal(red("Error resolving version: "), err)
+		}
+		specs = append(specs, versionFileSpec(spec, version))
+		if strings.Contains(spec, "@") {
+			fmt.Printf("%s .python-version can't hold the qualifier in %s, so %s is written and may pick another build\n", yellow("Note:"), spec, specs[len(specs)-1])
+		}
+	}
+
+	if err := writeVersionFile(path, specs); err != nil {
+		log.Fatal(red("Error: "), err)
+	}
+
+	fmt.Printf("%s %s %s %s\n", green("✓ Wrote"), green(strings.Join(specs, " ")), "to", path)
+	if getShimMode(config) != shimModeScript {
+		fmt.Printf("%s Symlink shims ignore .python-version files. Set \"shim_mode\": \"script\" in the config to use them.\n", yellow("Note:"))
+	}
+}
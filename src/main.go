@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -23,6 +24,24 @@ func main() {
 		handleInit()
 	case "current":
 		handleCurrent()
+	case "info":
+		handleInfo()
+	case "exec":
+		handleExec()
+	case "local":
+		handleLocal()
+	case "history":
+		handleHistory()
+	case "rehash":
+		handleRehash()
+	case "shell":
+		handleShell()
+	case "sh-shell":
+		handleShellScript()
+	case "restore-rc":
+		handleRestoreRC()
+	case "shims":
+		handleShimsCommand()
 	case "config", "configure":
 		handleConfigCommand()
 	case "--help", "-h", "help":
@@ -48,6 +67,26 @@ func handleConfigCommand() {
 	}
 }
 
+func handleShimsCommand() {
+	if len(os.Args) < 3 {
+		handleShimsList()
+		return
+	}
+
+	switch subCmd := os.Args[2]; subCmd {
+	case "list":
+		handleShimsList()
+	case "verify":
+		handleShimsVerify()
+	case "repair":
+		handleShimsRepair()
+	default:
+		fmt.Printf("%s Unknown shims subcommand: %s\n", red("Error:"), subCmd)
+		fmt.Printf("Available subcommands: %s, %s, %s\n", cyan("list"), cyan("verify"), cyan("repair"))
+		os.Exit(1)
+	}
+}
+
 func handleVersions() {
 	displayVersionsHeader()
 	
@@ -61,8 +100,13 @@ func handleVersions() {
 		return
 	}
 	
-	current := getCurrentVersion()
-	displayVersionsList(versions, current)
+	current, _ := getCurrentVersion()
+	cache := loadInfoCache(loadConfig())
+	displayVersionsList(versions, current, cache)
+	
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
 }
 
 func handleUse() {
@@ -76,43 +120,223 @@ func handleUse() {
 		return
 	}
 	
-	var version string
-	if len(os.Args) >= 3 {
-		version = os.Args[2]
+	force := false
+	var specs []string
+	for _, arg := range os.Args[2:] {
+		switch arg {
+		case "--force", "-f":
+			force = true
+		default:
+			// "-" is the previous version, not a flag
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				log.Fatalf("%s unknown flag %s; usage: brewpy use [--force] [version...]", red("Error:"), arg)
+			}
+			specs = append(specs, arg)
+		}
+	}
+	
+	config := loadConfig()
+	
+	var selected []Interpreter
+	if len(specs) > 0 {
+		selected, err = resolveSelection(config, versions, specs)
+		if err != nil {
+			log.Fatal(red("Error resolving version: "), err)
+		}
 	} else {
-		version, err = promptSelectVersion(versions)
+		version, err := promptSelectVersion(versions)
 		if err != nil {
 			log.Fatal(red("Error selecting version: "), err)
 		}
+		selected = []Interpreter{version}
 	}
+	version, secondary := selected[0], selected[1:]
 	
-	if !contains(versions, version) {
-		log.Fatalf("%s %s", red("Version not found:"), version)
+	cache := loadInfoCache(config)
+	for _, v := range selected {
+		problems := checkHealth(v, cache)
+		if len(selected) > 1 && len(problems) > 0 {
+			fmt.Printf("%s\n", v.FullName())
+			displayHealthProblems(problems, "  ")
+		} else {
+			displayHealthProblems(problems, "")
+		}
+		if hasErrors(problems) && !force {
+			log.Fatalf("%s %s is broken; %s or pass --force to use it anyway", red("Error:"), v.FullName(), reinstallHint(v))
+		}
 	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
+	
+	previous, hadPrevious, _ := loadState(config)
 	
-	err = createSymlinks(version)
+	plan, err := createSymlinks(version, secondary)
 	if err != nil {
 		log.Fatal(red("Error creating symlinks: "), err)
 	}
+	displayMissingShims(plan.Missing)
 	
-	err = updateShellProfile()
-	if err != nil {
-		log.Fatal(red("Error updating shell profile: "), err)
+	state := newState(config, version, secondary, plan)
+	if err := saveState(config, state); err != nil {
+		log.Fatal(red("Error saving state: "), err)
+	}
+	
+	added, removed := diffShims(previous.Scripts, plan.Scripts)
+	displayRehashResult(added, removed)
+	
+	if err := recordSwitch(config, previous, hadPrevious, state); err != nil {
+		fmt.Fprintf(os.Stderr, "%s Failed to record the switch in the history: %v\n", yellow("Warning:"), err)
 	}
 	
-	displaySuccessMessage(version)
+	// The switch has already happened, so report it even if a profile
+	// couldn't be written
+	profiles, profileErr := updateShellProfile()
+	
+	displaySuccessMessage(version, secondary, profiles)
+	
+	if profileErr != nil {
+		log.Fatal(red("Error updating shell profile: "), profileErr)
+	}
+}
+
+// resolveSelection resolves the versions given to `brewpy use`, primary
+// first. A history reference brings back every version of that switch.
+func resolveSelection(config Config, versions []Interpreter, specs []string) ([]Interpreter, error) {
+	var selected []Interpreter
+	for _, spec := range specs {
+		var resolved []Interpreter
+		if isHistoryRef(spec) {
+			var err error
+			if resolved, err = resolveHistoryRef(config, versions, spec); err != nil {
+				return nil, err
+			}
+		} else {
+			version, err := resolveVersion(versions, spec)
+			if err != nil {
+				return nil, err
+			}
+			resolved = []Interpreter{version}
+		}
+	
+		for _, version := range resolved {
+			duplicate := false
+			for _, other := range selected {
+				if version.Matches(other) {
+					duplicate = true
+					break
+				}
+				// Both would claim the same pythonX.Y shim
+				if version.MinorVersion() == other.MinorVersion() {
+					return nil, fmt.Errorf("%s and %s are both Python %s; pick one", other.FullName(), version.FullName(), version.MinorVersion())
+				}
+			}
+			if !duplicate {
+				selected = append(selected, version)
+			}
+		}
+	}
+	return selected, nil
 }
 
 func handleInit() {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatal(red("Error getting home directory: "), err)
+	// rc files name their shell, so detection only runs for a bare `brewpy init`
+	if len(os.Args) < 3 {
+		outputShellInit(detectShell())
+		return
+	}
+	
+	sh, ok := lookupShell(os.Args[2])
+	if !ok {
+		log.Fatalf("%s unsupported shell %q; supported shells: %s", red("Error:"), os.Args[2], strings.Join(shellNames(), ", "))
 	}
 	
-	outputShellInit(homeDir)
+	outputShellInit(sh)
 }
 
 func handleCurrent() {
-	current := getCurrentVersion()
-	displayCurrentVersion(current)
+	config := loadConfig()
+	
+	// A BREWPY_VERSION or .python-version override wins in script mode
+	if sel, ok := findActiveSelection(config); ok && !sel.Global {
+		versions, err := findPythonVersions()
+		if err != nil {
+			log.Fatal(red("Error finding Python versions: "), err)
+		}
+		
+		current, err := resolveVersion(versions, sel.Spec)
+		if err != nil {
+			log.Fatalf("%s version set by %s: %v", red("Error:"), sel.Origin, err)
+		}
+		
+		displayCurrentVersion(current, true)
+		displayOrigin(sel)
+		return
+	}
+	
+	current, ok := getCurrentVersion()
+	displayCurrentVersion(current, ok)
+	if !ok {
+		return
+	}
+	
+	state, hasState, _ := loadState(config)
+	if hasState {
+		displaySecondaryVersions(state)
+		displaySelectedAt(state)
+	}
+	
+	// Point out a version file the symlink shims can't honour
+	if cwd, err := os.Getwd(); err == nil && getShimMode(config) == shimModeSymlink {
+		if path, _, ok := findVersionFile(cwd); ok {
+			fmt.Printf("  %s %s is ignored in symlink shim mode\n", yellow("Note:"), path)
+		}
+	}
+	
+	cache := loadInfoCache(config)
+	if info, err := cache.Lookup(current); err == nil {
+		displayCurrentInfo(info)
+	}
+	
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
+	
+	if hasState {
+		displayDrift(checkDrift(config, state))
+	}
+}
+
+func handleInfo() {
+	var version Interpreter
+	if len(os.Args) >= 3 {
+		versions, err := findPythonVersions()
+		if err != nil {
+			log.Fatal(red("Error finding Python versions: "), err)
+		}
+		
+		version, err = resolveVersion(versions, os.Args[2])
+		if err != nil {
+			log.Fatal(red("Error resolving version: "), err)
+		}
+	} else {
+		current, ok := getCurrentVersion()
+		if !ok {
+			fmt.Printf("%s\n", yellow("No Python version currently managed by BrewPy. Pass a version, e.g. 'brewpy info 3.12'."))
+			return
+		}
+		version = current
+	}
+	
+	cache := loadInfoCache(loadConfig())
+	info, err := cache.Lookup(version)
+	if err != nil {
+		log.Fatal(red("Error inspecting interpreter: "), err)
+	}
+	
+	displayInterpreterInfo(version, info)
+	
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
 }
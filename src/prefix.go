@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Sources a Homebrew prefix can be detected from, in order of precedence
+const (
+	prefixFromConfig   = "config"
+	prefixFromEnv      = "$HOMEBREW_PREFIX"
+	prefixFromBrew     = "brew --prefix"
+	prefixFromKnown    = "well-known location"
+	prefixFromFallback = "fallback"
+)
+
+// getKnownPrefixes returns the locations Homebrew installs to by default
+func getKnownPrefixes() []string {
+	homeDir, _ := os.UserHomeDir()
+	return []string{
+		"/opt/homebrew",
+		"/usr/local",
+		"/home/linuxbrew/.linuxbrew",
+		filepath.Join(homeDir, ".linuxbrew"),
+	}
+}
+
+// isBrewPrefix reports whether dir looks like a Homebrew installation
+func isBrewPrefix(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "bin", "brew"))
+	return err == nil && !info.IsDir()
+}
+
+// detectBrewPrefix works out the Homebrew prefix at runtime and returns it
+// along with the source that supplied it
+func detectBrewPrefix(config Config) (string, string) {
+	if config.Prefix != "" {
+		return expandPath(config.Prefix), prefixFromConfig
+	}
+
+	if prefix := strings.TrimSpace(os.Getenv("HOMEBREW_PREFIX")); prefix != "" {
+		return prefix, prefixFromEnv
+	}
+
+	if prefix := brewPrefixFromCommand(); prefix != "" {
+		return prefix, prefixFromBrew
+	}
+
+	for _, prefix := range getKnownPrefixes() {
+		if isBrewPrefix(prefix) {
+			return prefix, prefixFromKnown
+		}
+	}
+
+	// Nothing found, fall back to the default for the compiled architecture
+	if runtime.GOOS == "linux" {
+		return "/home/linuxbrew/.linuxbrew", prefixFromFallback
+	}
+	if runtime.GOARCH == "arm64" {
+		return "/opt/homebrew", prefixFromFallback
+	}
+	return "/usr/local", prefixFromFallback
+}
+
+// withRecordedPrefix fills in the prefix `brewpy use` detected, so shims can
+// skip running `brew --prefix`. An explicit prefix still wins, and a recorded
+// prefix that no longer holds Homebrew is ignored.
+func withRecordedPrefix(config Config, state State) Config {
+	if config.Prefix != "" || os.Getenv("HOMEBREW_PREFIX") != "" || state.BrewPrefix == "" || !isBrewPrefix(state.BrewPrefix) {
+		return config
+	}
+	config.Prefix = state.BrewPrefix
+	return config
+}
+
+// brewPrefixFromCommand asks the brew on PATH for its prefix
+func brewPrefixFromCommand() string {
+	brewPath, err := exec.LookPath("brew")
+	if err != nil {
+		return ""
+	}
+
+	output, err := exec.Command(brewPath, "--prefix").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// brewPrefix is a Homebrew installation BrewPy scans for Python versions
+type brewPrefix struct {
+	Path   string
+	Source string
+}
+
+// getBrewPrefixes returns every Homebrew prefix to scan. The configured list
+// wins; otherwise the detected prefix comes first, followed by any other
+// well-known prefix that holds a Homebrew install (e.g. Rosetta /usr/local).
+func getBrewPrefixes(config Config) []brewPrefix {
+	var prefixes []brewPrefix
+	seen := map[string]bool{}
+
+	add := func(path, source string) {
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			prefixes = append(prefixes, brewPrefix{Path: path, Source: source})
+		}
+	}
+
+	if len(config.Prefixes) > 0 {
+		for _, prefix := range config.Prefixes {
+			add(expandPath(prefix), prefixFromConfig)
+		}
+		return prefixes
+	}
+
+	add(detectBrewPrefix(config))
+	for _, prefix := range getKnownPrefixes() {
+		if isBrewPrefix(prefix) {
+			add(prefix, prefixFromKnown)
+		}
+	}
+
+	return prefixes
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// damagedBlockError means an rc file's init markers don't form exactly one
+// block, so BrewPy can't tell which lines are its own
+type damagedBlockError struct {
+	Path    string
+	Problem string
+	Diff    []string // a suggested fix, as a unified diff
+}
+
+func (e *damagedBlockError) Error() string {
+	return fmt.Sprintf("brewpy init markers are damaged: %s; fix them by hand and run 'brewpy use' again", e.Problem)
+}
+
+// splitLines splits text into lines, ignoring the final newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// findBlock locates the init block in an rc file's lines. start and end are
+// -1 when there is no block. A non-empty problem means the markers are
+// orphaned, duplicated or out of order.
+func findBlock(lines []string) (int, int, string) {
+	var starts, ends []int
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case initComment:
+			starts = append(starts, i)
+		case initEndComment:
+			ends = append(ends, i)
+		}
+	}
+
+	switch {
+	case len(starts) == 0 && len(ends) == 0:
+		return -1, -1, ""
+	case len(starts) > 1:
+		return -1, -1, fmt.Sprintf("the start marker appears %d times (lines %s)", len(starts), lineNumbers(starts))
+	case len(ends) > 1:
+		return -1, -1, fmt.Sprintf("the end marker appears %d times (lines %s)", len(ends), lineNumbers(ends))
+	case len(ends) == 0:
+		return -1, -1, fmt.Sprintf("the start marker on line %d has no end marker", starts[0]+1)
+	case len(starts) == 0:
+		return -1, -1, fmt.Sprintf("the end marker on line %d has no start marker", ends[0]+1)
+	case ends[0] < starts[0]:
+		return -1, -1, fmt.Sprintf("the end marker on line %d comes before the start marker on line %d", ends[0]+1, starts[0]+1)
+	}
+	return starts[0], ends[0], ""
+}
+
+func lineNumbers(indexes []int) string {
+	numbers := make([]string, len(indexes))
+	for i, index := range indexes {
+		numbers[i] = fmt.Sprint(index + 1)
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// suggestBlockRepair drops the markers and the profile lines BrewPy wrote
+// next to or between them, and puts a fresh block where the first marker
+// was. Copies of a profile line elsewhere in the file are the user's own and
+// are kept. The result is only ever shown to the user, never written.
+func suggestBlockRepair(config Config, lines, block []string) []string {
+	ours := map[string]bool{`eval "$(brewpy init)"`: true}
+	for _, sh := range shells {
+		ours[sh.ProfileLine(config)] = true
+	}
+
+	var repaired []string
+	placed := false
+	for i, line := range lines {
+		drop := markerAt(lines, i) != ""
+		if !drop && ours[strings.TrimSpace(line)] {
+			drop = markerAt(lines, i-1) != "" || markerAt(lines, i+1) != "" || betweenMarkers(lines, i)
+		}
+
+		if !drop {
+			repaired = append(repaired, line)
+		} else if !placed {
+			repaired = append(repaired, block...)
+			placed = true
+		}
+	}
+	return repaired
+}
+
+// markerAt returns the init marker on line i, or "" if it isn't one
+func markerAt(lines []string, i int) string {
+	if i < 0 || i >= len(lines) {
+		return ""
+	}
+	switch line := strings.TrimSpace(lines[i]); line {
+	case initComment, initEndComment:
+		return line
+	}
+	return ""
+}
+
+// betweenMarkers reports whether line i lies between a start marker and the
+// end marker right after it
+func betweenMarkers(lines []string, i int) bool {
+	before, after := "", ""
+	for j := i - 1; j >= 0 && before == ""; j-- {
+		before = markerAt(lines, j)
+	}
+	for j := i + 1; j < len(lines) && after == ""; j++ {
+		after = markerAt(lines, j)
+	}
+	return before == initComment && after == initEndComment
+}
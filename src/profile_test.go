@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindBlock(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		start, end int
+		problem    string
+	}{
+		{
+			name:  "no block",
+			lines: []string{"export A=1"},
+			start: -1, end: -1,
+		},
+		{
+			name:  "one block",
+			lines: []string{"export A=1", initComment, `eval "$(brewpy init zsh)"`, initEndComment, "export B=2"},
+			start: 1, end: 3,
+		},
+		{
+			name:  "indented markers",
+			lines: []string{"  " + initComment, "  " + initEndComment},
+			start: 0, end: 1,
+		},
+		{
+			name:  "orphaned start",
+			lines: []string{"export A=1", initComment, `eval "$(brewpy init zsh)"`},
+			start: -1, end: -1,
+			problem: "the start marker on line 2 has no end marker",
+		},
+		{
+			name:  "orphaned end",
+			lines: []string{`eval "$(brewpy init zsh)"`, initEndComment},
+			start: -1, end: -1,
+			problem: "the end marker on line 2 has no start marker",
+		},
+		{
+			name:  "duplicated start",
+			lines: []string{initComment, "x", initComment, "y", initEndComment},
+			start: -1, end: -1,
+			problem: "the start marker appears 2 times (lines 1, 3)",
+		},
+		{
+			name:  "duplicated end",
+			lines: []string{initComment, initEndComment, "x", initEndComment},
+			start: -1, end: -1,
+			problem: "the end marker appears 2 times (lines 2, 4)",
+		},
+		{
+			name:  "out of order",
+			lines: []string{initEndComment, "x", initComment},
+			start: -1, end: -1,
+			problem: "the end marker on line 1 comes before the start marker on line 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, problem := findBlock(tt.lines)
+			if start != tt.start || end != tt.end || problem != tt.problem {
+				t.Errorf("findBlock() = %d, %d, %q; want %d, %d, %q", start, end, problem, tt.start, tt.end, tt.problem)
+			}
+		})
+	}
+}
+
+func TestSuggestBlockRepair(t *testing.T) {
+	config := Config{BrewPyDir: "/brewpy"}
+	line := shells["zsh"].ProfileLine(config)
+	block := []string{initComment, line, initEndComment}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name:  "orphaned start",
+			lines: []string{"export A=1", initComment, line, "export B=2"},
+			want:  []string{"export A=1", initComment, line, initEndComment, "export B=2"},
+		},
+		{
+			name:  "orphaned end",
+			lines: []string{"export A=1", line, initEndComment},
+			want:  []string{"export A=1", initComment, line, initEndComment},
+		},
+		{
+			name:  "duplicated block",
+			lines: []string{initComment, line, initEndComment, "export A=1", initComment, `eval "$(brewpy init)"`, initEndComment},
+			want:  []string{initComment, line, initEndComment, "export A=1"},
+		},
+		{
+			name:  "out of order",
+			lines: []string{initEndComment, "export A=1", initComment},
+			want:  []string{initComment, line, initEndComment, "export A=1"},
+		},
+		{
+			name:  "user's own copy is kept",
+			lines: []string{line, "export A=1", initComment, line},
+			want:  []string{line, "export A=1", initComment, line, initEndComment},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := suggestBlockRepair(config, tt.lines, block)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("suggestBlockRepair() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if _, _, problem := findBlock(got); problem != "" {
+				t.Errorf("suggested fix still has damaged markers: %s", problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed Python version such as 3.11, 3.11.9 or 3.13.0rc1
+type Version struct {
+	Major   int
+	Minor   int
+	Patch   int    // -1 when only major.minor is known
+	Pre     string // pre-release tag: "a1", "b2", "rc1"; empty for final releases
+	Variant string // build variant, e.g. "t" for free-threaded builds
+}
+
+// regex to match 3.11, 3.11.9, 3.13.0rc1, Python3.11 etc
+var versionRe = regexp.MustCompile(`^(?i:python)?(\d+)\.(\d+)(?:\.(\d+))?((?:a|b|rc)\d+)?(t)?$`)
+
+// regex to split a pre-release tag into its kind and number
+var preRe = regexp.MustCompile(`^(a|b|rc)(\d+)$`)
+
+// ParseVersion parses a version string, with or without a "Python" prefix
+func ParseVersion(s string) (Version, error) {
+	matches := versionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return Version{}, fmt.Errorf("invalid Python version: %q", s)
+	}
+
+	v := Version{Patch: -1, Pre: matches[4], Variant: matches[5]}
+	v.Major, _ = strconv.Atoi(matches[1])
+	v.Minor, _ = strconv.Atoi(matches[2])
+	if matches[3] != "" {
+		v.Patch, _ = strconv.Atoi(matches[3])
+	}
+
+	return v, nil
+}
+
+// String formats the version without a prefix, e.g. "3.11.9" or "3.13t"
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	if v.Patch >= 0 {
+		s += fmt.Sprintf(".%d", v.Patch)
+	}
+	return s + v.Pre + v.Variant
+}
+
+// MinorString returns the major.minor part with the variant, as used in
+// executable names, e.g. "3.11" or "3.13t"
+func (v Version) MinorString() string {
+	return fmt.Sprintf("%d.%d%s", v.Major, v.Minor, v.Variant)
+}
+
+// Compare orders versions numerically, returning -1, 0 or 1. Pre-releases
+// sort before the final release and the default build before variants.
+func (v Version) Compare(other Version) int {
+	if c := compareInts(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	if c := comparePre(v.Pre, other.Pre); c != 0 {
+		return c
+	}
+	return strings.Compare(v.Variant, other.Variant)
+}
+
+// SameMinor reports whether both versions belong to the same minor series
+// and build variant, e.g. 3.11.4 and 3.11.9
+func (v Version) SameMinor(other Version) bool {
+	return v.Major == other.Major && v.Minor == other.Minor && v.Variant == other.Variant
+}
+
+// Matches reports whether v satisfies a possibly less precise version, so
+// 3.11.9 matches 3.11 but not 3.11.4
+func (v Version) Matches(want Version) bool {
+	if !v.SameMinor(want) {
+		return false
+	}
+	if want.Patch < 0 {
+		return true
+	}
+	return v.Patch == want.Patch && v.Pre == want.Pre
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func comparePre(a, b string) int {
+	if a == b {
+		return 0
+	}
+	// A final release sorts after any pre-release
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	rank := map[string]int{"a": 0, "b": 1, "rc": 2}
+	am, bm := preRe.FindStringSubmatch(a), preRe.FindStringSubmatch(b)
+	if am == nil || bm == nil {
+		return strings.Compare(a, b)
+	}
+	if c := compareInts(rank[am[1]], rank[bm[1]]); c != 0 {
+		return c
+	}
+	an, _ := strconv.Atoi(am[2])
+	bn, _ := strconv.Atoi(bm[2])
+	return compareInts(an, bn)
+}
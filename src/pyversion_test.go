@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "3.11", want: Version{Major: 3, Minor: 11, Patch: -1}},
+		{in: "3.11.9", want: Version{Major: 3, Minor: 11, Patch: 9}},
+		{in: "Python3.11.9", want: Version{Major: 3, Minor: 11, Patch: 9}},
+		{in: "python3.12", want: Version{Major: 3, Minor: 12, Patch: -1}},
+		{in: "3.13.0rc1", want: Version{Major: 3, Minor: 13, Patch: 0, Pre: "rc1"}},
+		{in: "3.13t", want: Version{Major: 3, Minor: 13, Patch: -1, Variant: "t"}},
+		{in: "3.13.1t", want: Version{Major: 3, Minor: 13, Patch: 1, Variant: "t"}},
+		{in: "3", wantErr: true},
+		{in: "3.11.9_1", wantErr: true},
+		{in: "latest", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, in := range []string{"3.11", "3.11.9", "3.13.0rc1", "3.13t", "3.13.1t"} {
+		v, err := ParseVersion(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != in {
+			t.Errorf("ParseVersion(%q).String() = %q", in, v.String())
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.11.9", "3.11.9", 0},
+		{"3.9.1", "3.10.0", -1},
+		{"3.11.10", "3.11.9", 1},
+		{"3.12", "3.12.0", -1},
+		{"3.13.0rc1", "3.13.0", -1},
+		{"3.13.0b2", "3.13.0rc1", -1},
+		{"3.13.0a1", "3.13.0a2", -1},
+		{"3.13.1", "3.13.1t", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, _ := ParseVersion(tt.a)
+			b, _ := ParseVersion(tt.b)
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("Compare() = %d, want %d", got, tt.want)
+			}
+			if got := b.Compare(a); got != -tt.want {
+				t.Errorf("reversed Compare() = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	tests := []struct {
+		v, want string
+		match   bool
+	}{
+		{"3.11.9", "3.11", true},
+		{"3.11.9", "3.11.9", true},
+		{"3.11.9", "3.11.4", false},
+		{"3.12.1", "3.11", false},
+		{"3.13.1t", "3.13", false},
+		{"3.13.1t", "3.13t", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.v+" matches "+tt.want, func(t *testing.T) {
+			v, _ := ParseVersion(tt.v)
+			want, _ := ParseVersion(tt.want)
+			if got := v.Matches(want); got != tt.match {
+				t.Errorf("Matches() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
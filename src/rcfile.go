@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxBackups is how many backups are kept for each rc file
+	maxBackups = 10
+	// backupTimeFormat sorts backups of the same file oldest first by name
+	backupTimeFormat = "20060102T150405.000000"
+)
+
+// rcBackup is a copy of an rc file taken before BrewPy changed it
+type rcBackup struct {
+	Path    string // the rc file, as returned by normalizeRCPath
+	File    string // the backup under BrewPyDir
+	TakenAt time.Time
+}
+
+// getBackupsDir returns the directory rc file backups are kept in
+func getBackupsDir(brewPyDir string) string {
+	return filepath.Join(brewPyDir, "backups")
+}
+
+// writeRCFile replaces the contents of the rc file at path without losing
+// anything the user relies on. A symlinked rc (e.g. from stow) is resolved
+// on purpose so the file it points to is edited and the link stays intact.
+// The old contents are backed up, the existing mode and owner are kept, and
+// the new contents are written to a temp file and renamed into place so a
+// crash can't leave the file truncated.
+func writeRCFile(config Config, path string, data []byte) error {
+	target, err := resolveRCPath(path)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	uid, gid := -1, -1
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+		if err := backupRCFile(config, target); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+
+	return writeFileAtomic(target, data, mode, func(tmp string) error {
+		// The temp file belongs to whoever runs BrewPy, e.g. root under sudo
+		if uid >= 0 && (uid != os.Getuid() || gid != os.Getgid()) {
+			if err := os.Chown(tmp, uid, gid); err != nil && uid != os.Getuid() {
+				return fmt.Errorf("failed to keep the owner of %s: %w", target, err)
+			}
+		}
+		return nil
+	})
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place. prepare, if not nil, runs on the temp file just before the rename.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, prepare func(tmp string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".brewpy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if prepare != nil {
+		if err := prepare(tmp.Name()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resolveRCPath follows symlinks to the file that should be edited
+func resolveRCPath(path string) (string, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		if target != path {
+			fmt.Printf("%s %s is a link; editing %s\n", yellow("Note:"), path, target)
+		}
+		return target, nil
+	}
+
+	// A link to a file that doesn't exist would be replaced by a new file,
+	// so point it out instead
+	if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+		dest, _ := os.Readlink(path)
+		return "", fmt.Errorf("%s is a link to %s, which doesn't exist", path, dest)
+	}
+	if os.IsNotExist(err) {
+		return path, nil
+	}
+	return "", err
+}
+
+// normalizeRCPath returns the absolute path of the file an rc path refers
+// to, following symlinks as writeRCFile does, so that every spelling of the
+// same file finds the same backups
+func normalizeRCPath(path string) string {
+	path = expandPath(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// backupRCFile copies the rc file at target into the backups directory,
+// named after its normalized path, and prunes old backups of it
+func backupRCFile(config Config, target string) error {
+	path := normalizeRCPath(target)
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return err
+	}
+
+	dir := getBackupsDir(config.BrewPyDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	// rc files can hold secrets, so backups are private
+	name := time.Now().Format(backupTimeFormat) + "_" + url.PathEscape(path)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return err
+	}
+
+	backups := listBackups(config, path)
+	for _, backup := range backups[min(len(backups), maxBackups):] {
+		os.Remove(backup.File)
+	}
+	return nil
+}
+
+// listBackups returns the backups of the rc file at path, or of every rc
+// file when path is empty, newest first
+func listBackups(config Config, path string) []rcBackup {
+	if path != "" {
+		path = normalizeRCPath(path)
+	}
+
+	dir := getBackupsDir(config.BrewPyDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var backups []rcBackup
+	for _, entry := range entries {
+		stamp, escaped, ok := strings.Cut(entry.Name(), "_")
+		if !ok {
+			continue
+		}
+		takenAt, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		original, err := url.PathUnescape(escaped)
+		if err != nil {
+			continue
+		}
+		// Older backups may be named after a link to the file
+		original = normalizeRCPath(original)
+		if path != "" && original != path {
+			continue
+		}
+		backups = append(backups, rcBackup{Path: original, File: filepath.Join(dir, entry.Name()), TakenAt: takenAt})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].TakenAt.After(backups[j].TakenAt)
+	})
+	return backups
+}
+
+// handleRestoreRC brings back a backup of an rc file. With a path it restores
+// that file's newest backup; otherwise it asks which backup to restore. The
+// current contents are backed up first, so a restore can be undone.
+func handleRestoreRC() {
+	config := loadConfig()
+
+	var backup rcBackup
+	if len(os.Args) >= 3 {
+		path := normalizeRCPath(os.Args[2])
+		backups := listBackups(config, path)
+		if len(backups) == 0 {
+			log.Fatalf("%s no backups of %s in %s", red("Error:"), path, getBackupsDir(config.BrewPyDir))
+		}
+		backup = backups[0]
+	} else {
+		backups := listBackups(config, "")
+		if len(backups) == 0 {
+			fmt.Printf("%s\n", yellow("No rc file backups yet. BrewPy takes one before each change to an rc file."))
+			return
+		}
+
+		var err error
+		backup, err = promptSelectBackup(backups)
+		if err != nil {
+			log.Fatal(red("Error selecting backup: "), err)
+		}
+	}
+
+	data, err := os.ReadFile(backup.File)
+	if err != nil {
+		log.Fatal(red("Error reading backup: "), err)
+	}
+	if err := writeRCFile(config, backup.Path, data); err != nil {
+		log.Fatal(red("Error restoring rc file: "), err)
+	}
+	displayRestoredBackup(backup)
+}
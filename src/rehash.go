@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// findEntryPoints returns the console scripts installed for version, by
+// name. Only scripts whose shebang runs this interpreter count, so other
+// tools sharing a scripts directory (e.g. $HOMEBREW_PREFIX/bin) are left alone.
+func findEntryPoints(config Config, version Interpreter, info InterpreterInfo) map[string]string {
+	python, err := filepath.EvalSymlinks(version.Executable())
+	if err != nil {
+		return nil
+	}
+
+	// Versioned executables like pip3.11 are already covered by the shim set
+	skip := map[string]bool{}
+	for name, target := range shimTargets(config, version) {
+		skip[name] = true
+		skip[filepath.Base(target)] = true
+	}
+
+	scripts := map[string]string{}
+	for _, dir := range []string{info.Paths["scripts"], info.UserScripts} {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			path := filepath.Join(dir, name)
+			if skip[name] || pythonBinRe.MatchString(name) || scripts[name] != "" {
+				continue
+			}
+
+			stat, err := os.Stat(path)
+			if err != nil || stat.IsDir() || stat.Mode()&0111 == 0 {
+				continue
+			}
+
+			if interpreter := readShebang(path); interpreter != "" {
+				if resolved, err := filepath.EvalSymlinks(interpreter); err == nil && resolved == python {
+					scripts[name] = path
+				}
+			}
+		}
+	}
+
+	return scripts
+}
+
+// readShebang returns the interpreter a script runs. It understands pip's
+// workaround for long paths, where the script starts with /bin/sh and execs
+// the interpreter on its second line.
+func readShebang(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, _ := reader.ReadString('\n')
+	if !strings.HasPrefix(first, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(first, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	if fields[0] != "/bin/sh" {
+		return fields[0]
+	}
+
+	second, _ := reader.ReadString('\n')
+	if rest, ok := strings.CutPrefix(second, "'''exec' "); ok {
+		if quoted, _, ok := strings.Cut(strings.TrimPrefix(rest, `"`), `"`); ok {
+			return quoted
+		}
+	}
+	return ""
+}
+
+// rehash creates shims for the console scripts installed for the global
+// selection and removes the ones whose script is gone. It returns the names
+// it added and removed.
+func rehash(config Config) ([]string, []string, error) {
+	state, ok, err := loadState(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("no Python version selected; run 'brewpy use' first")
+	}
+
+	versions, err := findPythonVersions()
+	if err != nil {
+		return nil, nil, err
+	}
+	version := state.Interpreter(versions)
+
+	cache := loadInfoCache(config)
+	info, err := cache.Lookup(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
+
+	plan := shimPlan{Shims: state.Shims, Versioned: state.Versioned, Scripts: findEntryPoints(config, version, info)}
+	if err := installShims(config, state.Mode, plan.All()); err != nil {
+		return nil, nil, err
+	}
+
+	added, removed := diffShims(state.Scripts, plan.Scripts)
+	state.Scripts = plan.Scripts
+	if err := saveState(config, state); err != nil {
+		return nil, nil, err
+	}
+	return added, removed, nil
+}
+
+// diffShims returns the names added to and removed from a set of shims,
+// counting a changed target as added
+func diffShims(before, after map[string]string) ([]string, []string) {
+	var added, removed []string
+	for name, target := range after {
+		if before[name] != target {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func handleRehash() {
+	added, removed, err := rehash(loadConfig())
+	if err != nil {
+		log.Fatal(red("Error rehashing shims: "), err)
+	}
+	displayRehashResult(added, removed)
+}
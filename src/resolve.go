@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionClause is one comparison from a PEP 440 specifier, e.g. ">=3.10"
+type versionClause struct {
+	Op       string
+	Release  []int
+	Pre      string
+	Wildcard bool // "==3.11.*" style match
+}
+
+var (
+	// regex to match a single clause such as ">=3.10", "==3.11.*" or "~=3.12"
+	clauseRe = regexp.MustCompile(`^(===|==|!=|~=|>=|<=|>|<)?\s*(?i:python)?(\d+(?:\.\d+)*)((?:a|b|rc)\d+)?(\.\*)?$`)
+	// regex to match a Homebrew revision suffix such as "_1"
+	revisionRe = regexp.MustCompile(`^(.*)_(\d+)$`)
+)
+
+// resolveVersion picks the best installed interpreter for spec. Accepted
+// specs are exact names ("Python3.11.9_1"), partial versions ("3.12", "3"),
+// "latest", "previous" (newest of the second-newest minor series), "system"
+// (pyenv's word for the newest system interpreter) and PEP 440 ranges
+// (">=3.10,<3.13"). Any of them can carry an "@arch", "@source" or "@prefix"
+// qualifier. Every command that takes a version should go through here.
+func resolveVersion(versions []Interpreter, spec string) (Interpreter, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Interpreter{}, fmt.Errorf("no version given")
+	}
+
+	name, qualifier, _ := strings.Cut(spec, "@")
+	if strings.EqualFold(name, sourceSystem) && qualifier == "" {
+		name, qualifier = "latest", sourceSystem
+	}
+	candidates := filterQualifier(versions, qualifier)
+	// The system interpreters can be asked for by name, or by the prefix a
+	// selection recorded, even when the system source isn't enabled
+	if len(candidates) == 0 && (qualifier == sourceSystem || filepath.IsAbs(expandPath(qualifier))) {
+		if found, err := (systemSource{}).Discover(Config{}); err == nil {
+			candidates = filterQualifier(found, qualifier)
+		}
+	}
+
+	var matches, unknownPatch []Interpreter
+	switch strings.ToLower(name) {
+	case "latest":
+		matches = newestSeries(stableBuilds(candidates), 0)
+	case "previous":
+		matches = newestSeries(stableBuilds(candidates), 1)
+	default:
+		revision := -1
+		if m := revisionRe.FindStringSubmatch(name); m != nil {
+			name = m[1]
+			revision, _ = strconv.Atoi(m[2])
+		}
+
+		clauses, variant, err := parseSpecifier(name)
+		if err != nil {
+			return Interpreter{}, err
+		}
+
+		for _, v := range candidates {
+			if v.Version.Variant != variant || (revision >= 0 && v.Revision != revision) {
+				continue
+			}
+			ok, known := satisfiesAll(v.Version, clauses)
+			if ok {
+				matches = append(matches, v)
+			} else if !known {
+				unknownPatch = append(unknownPatch, v)
+			}
+		}
+		if !mentionsPre(clauses) && len(stableBuilds(matches)) > 0 {
+			matches = stableBuilds(matches)
+		}
+	}
+
+	if len(matches) == 0 {
+		if len(unknownPatch) > 0 {
+			return Interpreter{}, unknownPatchError(spec, unknownPatch)
+		}
+		return Interpreter{}, noMatchError(spec, versions)
+	}
+
+	best := matches[0]
+	for _, v := range matches[1:] {
+		if preferInterpreter(v, best) {
+			best = v
+		}
+	}
+	return best, nil
+}
+
+// filterQualifier keeps the interpreters whose arch, source or prefix
+// matches the qualifier, or all of them when it's empty
+func filterQualifier(versions []Interpreter, qualifier string) []Interpreter {
+	if qualifier == "" {
+		return versions
+	}
+
+	var filtered []Interpreter
+	for _, v := range versions {
+		if qualifier == v.Arch || qualifier == v.Source || filepath.Clean(expandPath(qualifier)) == v.Prefix {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// stableBuilds drops pre-releases and variant builds
+func stableBuilds(versions []Interpreter) []Interpreter {
+	var stable []Interpreter
+	for _, v := range versions {
+		if v.Version.Pre == "" && v.Version.Variant == "" {
+			stable = append(stable, v)
+		}
+	}
+	return stable
+}
+
+// newestSeries returns the interpreters in the nth newest minor series,
+// counting from zero
+func newestSeries(versions []Interpreter, n int) []Interpreter {
+	var series []Version
+	for _, v := range versions {
+		known := false
+		for _, s := range series {
+			if s.SameMinor(v.Version) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			series = append(series, Version{Major: v.Version.Major, Minor: v.Version.Minor, Patch: -1})
+		}
+	}
+
+	// Newest series first, so the nth newest is series[n]
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Compare(series[j]) > 0
+	})
+	if n >= len(series) {
+		return nil
+	}
+
+	var matches []Interpreter
+	for _, v := range versions {
+		if v.Version.SameMinor(series[n]) {
+			matches = append(matches, v)
+		}
+	}
+	return matches
+}
+
+// parseSpecifier splits a comma separated specifier into clauses. A bare
+// version like "3.12" becomes a prefix match. A trailing "t" on a bare
+// version selects the free-threaded variant.
+func parseSpecifier(spec string) ([]versionClause, string, error) {
+	variant := ""
+	var clauses []versionClause
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if strings.HasSuffix(part, "t") {
+			variant = "t"
+			part = strings.TrimSuffix(part, "t")
+		}
+
+		m := clauseRe.FindStringSubmatch(part)
+		if m == nil {
+			return nil, "", fmt.Errorf("invalid version specifier: %q", spec)
+		}
+
+		clause := versionClause{Op: m[1], Pre: m[3], Wildcard: m[4] != ""}
+		for _, field := range strings.Split(m[2], ".") {
+			n, _ := strconv.Atoi(field)
+			clause.Release = append(clause.Release, n)
+		}
+
+		if clause.Wildcard && clause.Op != "==" && clause.Op != "!=" {
+			return nil, "", fmt.Errorf("invalid version specifier: %q (.* only works with == and !=)", spec)
+		}
+		if clause.Op == "~=" && len(clause.Release) < 2 {
+			return nil, "", fmt.Errorf("invalid version specifier: %q (~= needs at least X.Y)", spec)
+		}
+
+		// A bare version matches everything that starts with it
+		if clause.Op == "" {
+			clause.Op = "=="
+			clause.Wildcard = clause.Pre == "" && len(clause.Release) < 3
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	return clauses, variant, nil
+}
+
+// satisfiesAll reports whether v satisfies every clause. When v's patch is
+// unknown, e.g. for an interpreter found only as python3.11, it's tried with
+// every patch that could change the answer; known is false when they disagree,
+// in which case v isn't accepted.
+func satisfiesAll(v Version, clauses []versionClause) (ok, known bool) {
+	if v.Patch >= 0 {
+		return satisfiesAllExact(v, clauses), true
+	}
+
+	// Only the patches around the ones the clauses name can change the answer
+	patches := []int{0, math.MaxInt32}
+	for _, clause := range clauses {
+		if len(clause.Release) >= 3 {
+			p := clause.Release[2]
+			patches = append(patches, max(p-1, 0), p, p+1)
+		}
+	}
+
+	v.Patch = patches[0]
+	ok = satisfiesAllExact(v, clauses)
+	for _, patch := range patches[1:] {
+		v.Patch = patch
+		if satisfiesAllExact(v, clauses) != ok {
+			return false, false
+		}
+	}
+	return ok, true
+}
+
+func satisfiesAllExact(v Version, clauses []versionClause) bool {
+	for _, clause := range clauses {
+		if !clause.satisfiedBy(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func mentionsPre(clauses []versionClause) bool {
+	for _, clause := range clauses {
+		if clause.Pre != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfiedBy checks v against the clause using PEP 440 comparison rules
+func (c versionClause) satisfiedBy(v Version) bool {
+	switch c.Op {
+	case "==", "===":
+		if c.Wildcard {
+			return c.hasPrefix(v)
+		}
+		return c.compare(v) == 0
+	case "!=":
+		if c.Wildcard {
+			return !c.hasPrefix(v)
+		}
+		return c.compare(v) != 0
+	case ">=":
+		return c.compare(v) >= 0
+	case "<=":
+		return c.compare(v) <= 0
+	case ">":
+		return c.compare(v) > 0
+	case "<":
+		return c.compare(v) < 0
+	case "~=":
+		// ~=3.11.2 means >=3.11.2 and ==3.11.*
+		compatible := versionClause{Release: c.Release[:len(c.Release)-1]}
+		return c.compare(v) >= 0 && compatible.hasPrefix(v)
+	}
+	return false
+}
+
+// compare orders v against the clause's version, padding missing release
+// fields with zeros
+func (c versionClause) compare(v Version) int {
+	release := []int{v.Major, v.Minor, v.Patch}
+	for i := 0; i < len(release) || i < len(c.Release); i++ {
+		a, b := 0, 0
+		if i < len(release) {
+			a = release[i]
+		}
+		if i < len(c.Release) {
+			b = c.Release[i]
+		}
+		if cmp := compareInts(a, b); cmp != 0 {
+			return cmp
+		}
+	}
+	return comparePre(v.Pre, c.Pre)
+}
+
+// hasPrefix reports whether v's release starts with the clause's fields
+func (c versionClause) hasPrefix(v Version) bool {
+	release := []int{v.Major, v.Minor, v.Patch}
+	for i, field := range c.Release {
+		if i >= len(release) || release[i] != field {
+			return false
+		}
+	}
+	return true
+}
+
+// unknownPatchError explains that some interpreters might match spec but
+// were left out because their patch version isn't known
+func unknownPatchError(spec string, versions []Interpreter) error {
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Label()
+	}
+	return fmt.Errorf("no installed Python is known to match %q, which depends on the patch version; these might match, but their patch version is unknown:\n  %s", spec, strings.Join(names, "\n  "))
+}
+
+// noMatchError lists the installed versions so users can see what's available
+func noMatchError(spec string, versions []Interpreter) error {
+	if len(versions) == 0 {
+		return fmt.Errorf("no installed Python matches %q; no versions are installed", spec)
+	}
+
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.Label()
+	}
+	return fmt.Errorf("no installed Python matches %q; installed versions:\n  %s", spec, strings.Join(names, "\n  "))
+}
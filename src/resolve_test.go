@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testInterpreter builds an installed interpreter from a name such as
+// "3.11.9_1", without touching the filesystem
+func testInterpreter(name, source, prefix, arch string) Interpreter {
+	revision := 0
+	if m := revisionRe.FindStringSubmatch(name); m != nil {
+		name = m[1]
+		revision, _ = strconv.Atoi(m[2])
+	}
+	version, err := ParseVersion(name)
+	if err != nil {
+		panic(err)
+	}
+	return Interpreter{Version: version, Revision: revision, Source: source, Prefix: prefix, Arch: arch}
+}
+
+func TestResolveVersion(t *testing.T) {
+	versions := []Interpreter{
+		testInterpreter("3.10.14", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.11.4", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.11.9_1", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.11.9", sourceHomebrew, "/usr/local", "x86_64"),
+		testInterpreter("3.12.4", sourceUV, "/uv/cpython-3.12.4", "arm64"),
+		testInterpreter("3.12.5", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.13.1", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.13.1t", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.14.0rc1", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.8.9", sourceSystem, "/usr", "arm64"),
+	}
+
+	tests := []struct {
+		spec    string
+		want    string // Label of the chosen interpreter
+		wantErr string
+	}{
+		{spec: "3", want: "Python3.13.1 (arm64, /opt/homebrew)"},
+		{spec: "latest", want: "Python3.13.1 (arm64, /opt/homebrew)"},
+		{spec: "previous", want: "Python3.12.5 (arm64, /opt/homebrew)"},
+		{spec: "3.11", want: "Python3.11.9_1 (arm64, /opt/homebrew)"},
+		{spec: "3.11.4", want: "Python3.11.4 (arm64, /opt/homebrew)"},
+		{spec: "Python3.11.9_1", want: "Python3.11.9_1 (arm64, /opt/homebrew)"},
+		{spec: "~=3.11.2", want: "Python3.11.9_1 (arm64, /opt/homebrew)"},
+		{spec: "~=3.11", want: "Python3.13.1 (arm64, /opt/homebrew)"},
+		{spec: ">=3.10,<3.12", want: "Python3.11.9_1 (arm64, /opt/homebrew)"},
+		{spec: "!=3.13.*,>=3.12", want: "Python3.12.5 (arm64, /opt/homebrew)"},
+		{spec: "3.13t", want: "Python3.13.1t (arm64, /opt/homebrew)"},
+		{spec: "3.14", want: "Python3.14.0rc1 (arm64, /opt/homebrew)"},
+		{spec: "==3.14.0rc1", want: "Python3.14.0rc1 (arm64, /opt/homebrew)"},
+		{spec: "3.11@x86_64", want: "Python3.11.9 (x86_64, /usr/local)"},
+		{spec: "3.11@/usr/local", want: "Python3.11.9 (x86_64, /usr/local)"},
+		{spec: "3.12@uv", want: "Python3.12.4 (arm64, uv: /uv/cpython-3.12.4)"},
+		{spec: "latest@uv", want: "Python3.12.4 (arm64, uv: /uv/cpython-3.12.4)"},
+		{spec: "system", want: "Python3.8.9 (arm64, system: /usr)"},
+		{spec: "3.8@system", want: "Python3.8.9 (arm64, system: /usr)"},
+		{spec: "3.9", wantErr: "no installed Python matches"},
+		{spec: "3.12@x86_64", wantErr: "no installed Python matches"},
+		{spec: ">=3.11,>", wantErr: "invalid version specifier"},
+		{spec: ">=3.*", wantErr: ".* only works with == and !="},
+		{spec: "~=3", wantErr: "~= needs at least X.Y"},
+		{spec: "", wantErr: "no version given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := resolveVersion(versions, tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveVersion(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveVersion(%q) error = %v", tt.spec, err)
+			}
+			if got.Label() != tt.want {
+				t.Errorf("resolveVersion(%q) = %s, want %s", tt.spec, got.Label(), tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVersionUnknownPatch(t *testing.T) {
+	// Found only as bin/python3.11, so the patch is unknown
+	versions := []Interpreter{testInterpreter("3.11", sourceHomebrew, "/usr/local", "x86_64")}
+
+	tests := []struct {
+		spec    string
+		match   bool
+		unknown bool // rejected because the answer depends on the patch
+	}{
+		{spec: "3.11", match: true},
+		{spec: "==3.11.*", match: true},
+		{spec: ">=3.10", match: true},
+		{spec: "<3.12", match: true},
+		{spec: ">=3.12"},
+		{spec: ">=3.11.1", unknown: true},
+		{spec: "<=3.11", unknown: true},
+		{spec: "~=3.11.2", unknown: true},
+		{spec: "3.11.4", unknown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := resolveVersion(versions, tt.spec)
+			if tt.match {
+				if err != nil {
+					t.Errorf("resolveVersion(%q) error = %v, want a match", tt.spec, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("resolveVersion(%q) matched, want an error", tt.spec)
+			}
+			if got := strings.Contains(err.Error(), "patch version is unknown"); got != tt.unknown {
+				t.Errorf("resolveVersion(%q) error = %v, want unknown patch %v", tt.spec, err, tt.unknown)
+			}
+		})
+	}
+}
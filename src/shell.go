@@ -1,54 +1,231 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-func updateShellProfile() error {
+// updateShellProfile adds the init block to every configured rc file and
+// returns the ones that have it now
+func updateShellProfile() ([]string, error) {
 	config := loadConfig()
-	
-	content, err := os.ReadFile(config.ShellRC)
+
+	var updated []string
+	var errs []error
+	for _, path := range getProfiles(config) {
+		err := updateProfile(config, path)
+		// A damaged block only leaves that rc file alone; the suggested fix
+		// is shown and the other profiles are still updated
+		var damaged *damagedBlockError
+		if errors.As(err, &damaged) {
+			displayDamagedBlock(damaged)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		updated = append(updated, path)
+	}
+	return updated, errors.Join(errs...)
+}
+
+// updateProfile puts the init block in one rc file. An existing block is
+// updated where it is, so the rc's order is kept; otherwise the block is
+// appended. The file is only written when its contents change.
+func updateProfile(config Config, path string) error {
+	content, err := os.ReadFile(path)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	lines := strings.Split(string(content), "\n")
+	// Shells that can't eval the init code source a file written here
+	sh := profileShell(path)
+	if s, ok := sh.(initFileShell); ok {
+		code := sh.Init(getShimsDir(config.BrewPyDir), getBrewPyExecutable())
+		if current, err := os.ReadFile(s.InitFile(config)); err != nil || string(current) != code {
+			if err := os.WriteFile(s.InitFile(config), []byte(code), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	text := string(content)
+	lines := splitLines(text)
+	block := profileBlock(config, path)
 
-	// Remove existing brewpy init block
-	startIdx, endIdx := -1, -1
-	for i, line := range lines {
-		if line == initComment {
-			startIdx = i
+	start, end, problem := findBlock(lines)
+	if problem != "" {
+		return &damagedBlockError{
+			Path:    path,
+			Problem: problem,
+			Diff:    unifiedDiff(lines, suggestBlockRepair(config, lines, block), 3),
 		}
-		if line == initEndComment {
-			endIdx = i
+	}
+
+	var updated []string
+	if start >= 0 {
+		updated = append(updated, lines[:start]...)
+		updated = append(updated, block...)
+		updated = append(updated, lines[end+1:]...)
+	} else {
+		updated = append(updated, lines...)
+		if len(updated) > 0 && strings.TrimSpace(updated[len(updated)-1]) != "" {
+			updated = append(updated, "")
 		}
+		updated = append(updated, block...)
+	}
+
+	// Keep the file's own choice about a final newline
+	newText := strings.Join(updated, "\n")
+	if text == "" || strings.HasSuffix(text, "\n") {
+		newText += "\n"
+	}
+	if newText == text {
+		return nil
 	}
 
-	if startIdx != -1 && endIdx != -1 && startIdx < endIdx {
-		lines = append(lines[:startIdx], lines[endIdx+1:]...)
+	// Write back to file, creating e.g. ~/.config/fish if the shell hasn't yet
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	return writeRCFile(config, path, []byte(newText))
+}
 
-	// Add brewpy init block
-	initBlock := []string{
-		"",
+// profileBlock returns the marked init block for the rc file at path
+func profileBlock(config Config, path string) []string {
+	return []string{
 		initComment,
-		`eval "$(brewpy init)"`,
+		profileShell(path).ProfileLine(config),
 		initEndComment,
 	}
+}
 
-	// Append init block at the end
-	lines = append(lines, initBlock...)
+// profileStatus describes whether the rc file at path has an up to date init
+// block. The bool is false when `brewpy use` would need to change it.
+func profileStatus(config Config, path string) (bool, string) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, "does not exist yet"
+	}
+	if err != nil {
+		return false, fmt.Sprintf("can't be read: %v", err)
+	}
 
-	// Write back to file
-	return os.WriteFile(config.ShellRC, []byte(strings.Join(lines, "\n")), fs.ModePerm)
+	lines := splitLines(string(content))
+	start, end, problem := findBlock(lines)
+	switch {
+	case problem != "":
+		return false, "has damaged brewpy init markers: " + problem
+	case start < 0:
+		return false, "has no brewpy init block"
+	case strings.Join(lines[start:end+1], "\n") != strings.Join(profileBlock(config, path), "\n"):
+		return false, "has an outdated brewpy init block"
+	}
+	return true, fmt.Sprintf("has the brewpy init block for %s", profileShell(path).Name())
 }
 
-func outputShellInit(homeDir string) {
+func outputShellInit(sh shellSyntax) {
 	config := loadConfig()
-	shimsPath := getShimsDir(config.BrewPyDir)
-	fmt.Printf("export PATH=\"%s:$PATH\"", shimsPath)
-} 
\ No newline at end of file
+	fmt.Print(sh.Init(getShimsDir(config.BrewPyDir), getBrewPyExecutable()))
+}
+
+// handleShell runs when `brewpy shell` is called without the wrapper
+// function from `brewpy init`, so it can only explain what's missing
+func handleShell() {
+	if len(os.Args) < 3 {
+		if spec := os.Getenv(versionEnvVar); spec != "" {
+			fmt.Printf("%s %s\n", green(versionEnvVar+"="), green(spec))
+		} else {
+			fmt.Printf("%s\n", yellow("No shell-specific version set"))
+		}
+		return
+	}
+
+	fmt.Printf("%s 'brewpy shell' needs the shell integration. Add this to your shell profile and restart the shell:\n", red("Error:"))
+	fmt.Printf("  %s\n", detectShell().ProfileLine(loadConfig()))
+	os.Exit(1)
+}
+
+// handleShellScript prints the commands the `brewpy shell` wrapper function
+// evals. Everything meant for the user goes to stderr.
+func handleShellScript() {
+	args := os.Args[2:]
+	sh := shells["sh"]
+	if len(args) >= 2 && args[0] == "--shell" {
+		if named, ok := lookupShell(args[1]); ok {
+			sh = named
+		}
+		args = args[2:]
+	}
+
+	if len(args) == 0 {
+		if spec := os.Getenv(versionEnvVar); spec != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", spec)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", yellow("No shell-specific version set"))
+		}
+		fmt.Printf("%s\n", sh.Status(true))
+		return
+	}
+
+	if args[0] == "--unset" {
+		if os.Getenv(shellShimsEnvVar) != "" {
+			fmt.Printf("%s\n", sh.SetEnv("PATH", shellPath("")))
+			fmt.Printf("%s\n", sh.UnsetEnv(shellShimsEnvVar))
+		}
+		fmt.Printf("%s\n", sh.UnsetEnv(versionEnvVar))
+		return
+	}
+
+	versions, err := findPythonVersions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", red("Error finding Python versions:"), err)
+		fmt.Printf("%s\n", sh.Status(false))
+		return
+	}
+
+	config := loadConfig()
+	spec := args[0]
+	version, err := resolveVersion(versions, spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", red("Error resolving version:"), err)
+		fmt.Printf("%s\n", sh.Status(false))
+		return
+	}
+
+	// Symlink shims can't read the variable, so the shell gets its own set
+	if getShimMode(config) != shimModeScript {
+		dir, err := installShellShims(config, version, versions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", red("Error:"), err)
+			fmt.Printf("%s\n", sh.Status(false))
+			return
+		}
+		fmt.Printf("%s\n", sh.SetEnv("PATH", shellPath(dir)))
+		fmt.Printf("%s\n", sh.SetEnv(shellShimsEnvVar, dir))
+	}
+
+	fmt.Printf("%s\n", sh.SetEnv(versionEnvVar, spec))
+	fmt.Fprintf(os.Stderr, "%s %s\n", green("✓ This shell now uses"), green(version.Label()))
+}
+
+// shellPath returns $PATH without the shims an earlier `brewpy shell` added,
+// with dir in front when it isn't empty
+func shellPath(dir string) string {
+	previous := os.Getenv(shellShimsEnvVar)
+
+	var entries []string
+	if dir != "" {
+		entries = append(entries, dir)
+	}
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if (previous == "" || entry != previous) && entry != dir {
+			entries = append(entries, entry)
+		}
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shellSyntax knows how to talk to one kind of shell: the code `brewpy init`
+// prints, the line that loads it from the rc file, and the commands the
+// `brewpy shell` wrapper evals
+type shellSyntax interface {
+	Name() string
+	// Init returns the code that puts the shims on PATH and defines the
+	// brewpy wrapper function
+	Init(shimsPath, brewpy string) string
+	// ProfileLine returns the rc file line that loads the init code
+	ProfileLine(config Config) string
+	SetEnv(name, value string) string
+	UnsetEnv(name string) string
+	// Status returns a command that does nothing, failing if ok is false
+	Status(ok bool) string
+}
+
+// initFileShell is implemented by shells that can't eval generated code at
+// startup. BrewPy writes their init code to a file that the rc sources.
+type initFileShell interface {
+	InitFile(config Config) string
+}
+
+// shells maps the names accepted by `brewpy init` to their syntax
+var shells = map[string]shellSyntax{
+	"zsh":        posixShell{"zsh"},
+	"bash":       posixShell{"bash"},
+	"sh":         posixShell{"sh"},
+	"fish":       fishShell{},
+	"nu":         nuShell{},
+	"nushell":    nuShell{},
+	"pwsh":       pwshShell{},
+	"powershell": pwshShell{},
+	"xonsh":      xonshShell{},
+	"elvish":     elvishShell{},
+}
+
+// shellNames returns the names of the supported shells, without aliases
+func shellNames() []string {
+	var names []string
+	for name, sh := range shells {
+		if sh.Name() == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupShell finds the syntax for a shell by name or executable path
+func lookupShell(name string) (shellSyntax, bool) {
+	name = strings.TrimSuffix(strings.ToLower(filepath.Base(name)), ".exe")
+	// Login shells show up as "-zsh"
+	sh, ok := shells[strings.TrimPrefix(name, "-")]
+	return sh, ok
+}
+
+// shellForRC guesses the shell an rc file belongs to from its name
+func shellForRC(path string) (shellSyntax, bool) {
+	base := filepath.Base(path)
+	switch {
+	case base == ".zshrc" || base == ".zprofile" || base == ".zshenv" || base == ".zlogin":
+		return shells["zsh"], true
+	case base == ".bashrc" || base == ".bash_profile" || base == ".bash_login":
+		return shells["bash"], true
+	case base == ".profile":
+		return shells["sh"], true
+	case strings.HasSuffix(base, ".fish"):
+		return shells["fish"], true
+	case strings.HasSuffix(base, ".nu"):
+		return shells["nu"], true
+	case strings.HasSuffix(base, ".ps1"):
+		return shells["pwsh"], true
+	case base == ".xonshrc" || strings.HasSuffix(base, ".xsh"):
+		return shells["xonsh"], true
+	case strings.HasSuffix(base, ".elv"):
+		return shells["elvish"], true
+	}
+	return nil, false
+}
+
+// profileShell returns the shell whose syntax goes in the rc file at path
+func profileShell(path string) shellSyntax {
+	if sh, ok := shellForRC(path); ok {
+		return sh
+	}
+	return detectShell()
+}
+
+// posixShell covers zsh, bash and other POSIX shells
+type posixShell struct {
+	name string
+}
+
+func (s posixShell) Name() string { return s.name }
+
+func (s posixShell) Init(shimsPath, brewpy string) string {
+	// `brewpy shell` has to change the calling shell's environment, which a
+	// child process can't do, so a wrapper function evals its output
+	return fmt.Sprintf(`export PATH=%s:"$PATH"
+brewpy() {
+  case "$1" in
+    shell)
+      shift
+      eval "$(command brewpy sh-shell "$@")"
+      ;;
+    *)
+      command brewpy "$@"
+      ;;
+  esac
+}
+`, shellQuote(shimsPath))
+}
+
+func (s posixShell) ProfileLine(config Config) string {
+	return fmt.Sprintf(`eval "$(brewpy init %s)"`, s.name)
+}
+
+func (posixShell) SetEnv(name, value string) string {
+	return fmt.Sprintf("export %s=%s", name, shellQuote(value))
+}
+
+func (posixShell) UnsetEnv(name string) string {
+	return "unset " + name
+}
+
+func (posixShell) Status(ok bool) string {
+	return strconv.FormatBool(ok)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type fishShell struct{}
+
+func (fishShell) Name() string { return "fish" }
+
+func (fishShell) Init(shimsPath, brewpy string) string {
+	return fmt.Sprintf(`set -gx PATH %s $PATH
+function brewpy
+  if test "$argv[1]" = shell
+    command brewpy sh-shell --shell fish $argv[2..-1] | source
+  else
+    command brewpy $argv
+  end
+end
+`, fishQuote(shimsPath))
+}
+
+func (fishShell) ProfileLine(config Config) string {
+	return "brewpy init fish | source"
+}
+
+func (fishShell) SetEnv(name, value string) string {
+	return fmt.Sprintf("set -gx %s %s", name, fishQuote(value))
+}
+
+func (fishShell) UnsetEnv(name string) string {
+	return "set -e " + name
+}
+
+func (fishShell) Status(ok bool) string {
+	return strconv.FormatBool(ok)
+}
+
+// fishQuote quotes s for fish, where only \ and ' are special inside quotes
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// nuShell is nushell. It parses a whole file before running it, so the rc
+// sources a file BrewPy writes, and `brewpy shell` hands back one JSON record
+// per change.
+type nuShell struct{}
+
+func (nuShell) Name() string { return "nu" }
+
+func (nuShell) Init(shimsPath, brewpy string) string {
+	return fmt.Sprintf(`$env.PATH = ($env.PATH | split row (char esep) | prepend %s)
+def --env --wrapped brewpy [...args] {
+  if ($args | is-not-empty) and ($args.0 == "shell") {
+    let changes = (^%s sh-shell --shell nu ...($args | skip 1) | lines | each {|line| $line | from json })
+    load-env ($changes | each {|change| $change.set? | default {} } | reduce --fold {} {|it, acc| $acc | merge $it })
+    for name in ($changes | each {|change| $change.unset? } | compact) { hide-env -i $name }
+  } else {
+    ^%s ...$args
+  }
+}
+`, strconv.Quote(shimsPath), strconv.Quote(brewpy), strconv.Quote(brewpy))
+}
+
+func (nuShell) InitFile(config Config) string {
+	return filepath.Join(config.BrewPyDir, "init.nu")
+}
+
+func (s nuShell) ProfileLine(config Config) string {
+	return "source " + strconv.Quote(s.InitFile(config))
+}
+
+func (nuShell) SetEnv(name, value string) string {
+	// nushell keeps PATH as a list
+	var v any = value
+	if name == "PATH" {
+		v = filepath.SplitList(value)
+	}
+	data, _ := json.Marshal(map[string]any{"set": map[string]any{name: v}})
+	return string(data)
+}
+
+func (nuShell) UnsetEnv(name string) string {
+	data, _ := json.Marshal(map[string]string{"unset": name})
+	return string(data)
+}
+
+func (nuShell) Status(ok bool) string {
+	return "{}"
+}
+
+// pwshShell is PowerShell
+type pwshShell struct{}
+
+func (pwshShell) Name() string { return "pwsh" }
+
+func (pwshShell) Init(shimsPath, brewpy string) string {
+	return fmt.Sprintf(`$env:PATH = %s + [System.IO.Path]::PathSeparator + $env:PATH
+function brewpy {
+  if ($args.Count -gt 0 -and $args[0] -eq 'shell') {
+    $script = (& %s sh-shell --shell pwsh @($args | Select-Object -Skip 1)) -join [Environment]::NewLine
+    Invoke-Expression $script
+  } else {
+    & %s @args
+  }
+}
+`, pwshQuote(shimsPath), pwshQuote(brewpy), pwshQuote(brewpy))
+}
+
+func (pwshShell) ProfileLine(config Config) string {
+	return "Invoke-Expression (& brewpy init pwsh | Out-String)"
+}
+
+func (pwshShell) SetEnv(name, value string) string {
+	return fmt.Sprintf("$env:%s = %s", name, pwshQuote(value))
+}
+
+func (pwshShell) UnsetEnv(name string) string {
+	return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", name)
+}
+
+func (pwshShell) Status(ok bool) string {
+	if ok {
+		return "$global:LASTEXITCODE = 0"
+	}
+	return "$global:LASTEXITCODE = 1"
+}
+
+// pwshQuote quotes s as a PowerShell verbatim string
+func pwshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+type xonshShell struct{}
+
+func (xonshShell) Name() string { return "xonsh" }
+
+func (xonshShell) Init(shimsPath, brewpy string) string {
+	return fmt.Sprintf(`$PATH.insert(0, %s)
+def _brewpy(args):
+    if args and args[0] == 'shell':
+        execx($(@(%s) sh-shell --shell xonsh @(args[1:])))
+    else:
+        @(%s) @(args)
+aliases['brewpy'] = _brewpy
+del _brewpy
+`, strconv.Quote(shimsPath), strconv.Quote(brewpy), strconv.Quote(brewpy))
+}
+
+func (xonshShell) ProfileLine(config Config) string {
+	return "execx($(brewpy init xonsh))"
+}
+
+func (xonshShell) SetEnv(name, value string) string {
+	return fmt.Sprintf("$%s = %s", name, strconv.Quote(value))
+}
+
+func (xonshShell) UnsetEnv(name string) string {
+	return fmt.Sprintf("${...}.pop(%s, None)", strconv.Quote(name))
+}
+
+func (xonshShell) Status(ok bool) string {
+	return "pass"
+}
+
+type elvishShell struct{}
+
+func (elvishShell) Name() string { return "elvish" }
+
+func (elvishShell) Init(shimsPath, brewpy string) string {
+	// Functions defined inside eval stay there, so the wrapper is added to
+	// the interactive namespace explicitly
+	return fmt.Sprintf(`set paths = [%s $@paths]
+fn brewpy {|@args|
+  if (and (> (count $args) 0) (eq $args[0] shell)) {
+    eval (e:brewpy sh-shell --shell elvish $@args[1..] | slurp)
+  } else {
+    e:brewpy $@args
+  }
+}
+edit:add-var brewpy~ $brewpy~
+`, elvishQuote(shimsPath))
+}
+
+func (elvishShell) ProfileLine(config Config) string {
+	return "eval (brewpy init elvish | slurp)"
+}
+
+func (elvishShell) SetEnv(name, value string) string {
+	return fmt.Sprintf("set-env %s %s", name, elvishQuote(value))
+}
+
+func (elvishShell) UnsetEnv(name string) string {
+	return "unset-env " + name
+}
+
+func (elvishShell) Status(ok bool) string {
+	if ok {
+		return "nop"
+	}
+	return "fail 'brewpy shell failed'"
+}
+
+// elvishQuote quotes s for elvish, which doubles a quote inside single quotes
+func elvishQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
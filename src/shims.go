@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// shimStatus describes one shim found on disk or recorded in the state
+type shimStatus struct {
+	Name    string
+	Target  string // the executable the shim runs
+	Managed bool   // recorded in the state by `brewpy use` or rehash
+	Problem string // empty when the shim is fine
+}
+
+// inspectShims checks every shim on disk and every shim the state records
+func inspectShims(config Config, state State) []shimStatus {
+	shimsPath := getShimsDir(config.BrewPyDir)
+	recorded := state.AllShims()
+
+	seen := map[string]bool{}
+	var names []string
+	for name := range recorded {
+		seen[name] = true
+		names = append(names, name)
+	}
+	entries, _ := os.ReadDir(shimsPath)
+	for _, entry := range entries {
+		if !seen[entry.Name()] {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	statuses := make([]shimStatus, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(shimsPath, name)
+		want, managed := recorded[name]
+		status := shimStatus{Name: name, Target: want, Managed: managed}
+
+		got, linkErr := os.Readlink(path)
+		if linkErr == nil {
+			status.Target = got
+		}
+
+		switch {
+		case !managed:
+			status.Problem = "not created by BrewPy"
+		case state.Mode == shimModeScript:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				status.Problem = "missing (expected launcher script)"
+			} else if string(content) != launcherScript(name) {
+				status.Problem = "not the launcher script BrewPy writes"
+			}
+		case linkErr != nil:
+			status.Problem = fmt.Sprintf("missing (expected link to %s)", want)
+		case got != want:
+			status.Problem = fmt.Sprintf("points to %s (expected %s)", got, want)
+		}
+
+		if status.Problem == "" && status.Target != "" {
+			status.Problem = checkExecutable(status.Target)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// checkDrift compares the shims on disk with the ones recorded in the state
+// and describes every difference
+func checkDrift(config Config, state State) []string {
+	var drift []string
+	for _, status := range inspectShims(config, state) {
+		if status.Problem != "" {
+			drift = append(drift, fmt.Sprintf("%s: %s", status.Name, status.Problem))
+		}
+	}
+	return drift
+}
+
+// repairShims rebuilds the shim set from the selection recorded in the
+// state. A recorded version that has been upgraded away is replaced by its
+// successor in the same series, and secondary versions that are gone
+// entirely are left out. Anything BrewPy didn't create is removed along with
+// the old set. It returns the shim names it dropped and removed.
+func repairShims(config Config) ([]string, []string, error) {
+	state, ok, err := loadState(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("no Python version selected; run 'brewpy use' first")
+	}
+
+	versions, err := findPythonVersions()
+	if err != nil {
+		return nil, nil, err
+	}
+	version, ok := state.resolve(versions)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is no longer installed; run 'brewpy use' to pick another version", state.Label())
+	}
+	if version.FullName() != state.Version {
+		fmt.Printf("%s %s is no longer installed; using %s instead\n", yellow("Note:"), state.Version, version.FullName())
+	}
+
+	var secondary []Interpreter
+	var selectedSecondary []selectedVersion
+	for _, s := range state.Secondary {
+		v, ok := s.resolve(versions)
+		if !ok {
+			fmt.Printf("%s %s is no longer installed; leaving it out\n", yellow("Note:"), s.Label())
+			continue
+		}
+		if v.FullName() != s.Version {
+			fmt.Printf("%s %s is no longer installed; using %s instead\n", yellow("Note:"), s.Version, v.FullName())
+		}
+		secondary = append(secondary, v)
+		selectedSecondary = append(selectedSecondary, selectVersion(v))
+	}
+
+	var removed []string
+	for _, status := range inspectShims(config, state) {
+		if !status.Managed {
+			removed = append(removed, status.Name)
+		}
+	}
+
+	plan := planShims(config, version, secondary)
+	shims := plan.All()
+	var dropped []string
+	for name := range state.AllShims() {
+		if _, ok := shims[name]; !ok {
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+
+	if err := installShims(config, state.Mode, shims); err != nil {
+		return nil, nil, err
+	}
+
+	state.selectedVersion = selectVersion(version)
+	state.Secondary = selectedSecondary
+	state.Shims = plan.Shims
+	state.Versioned = plan.Versioned
+	state.Scripts = plan.Scripts
+	if err := saveState(config, state); err != nil {
+		return nil, nil, err
+	}
+	return dropped, removed, nil
+}
+
+func handleShimsList() {
+	config := loadConfig()
+	state, _, err := loadState(config)
+	if err != nil {
+		log.Fatal(red("Error reading state: "), err)
+	}
+
+	statuses := inspectShims(config, state)
+	if len(statuses) == 0 {
+		fmt.Printf("%s\n", yellow("No shims yet. Use 'brewpy use' to select a version."))
+		return
+	}
+	displayShimList(getShimsDir(config.BrewPyDir), statuses)
+}
+
+func handleShimsVerify() {
+	config := loadConfig()
+	state, _, err := loadState(config)
+	if err != nil {
+		log.Fatal(red("Error reading state: "), err)
+	}
+
+	drift := checkDrift(config, state)
+	if len(drift) > 0 {
+		displayDrift(drift)
+		os.Exit(1)
+	}
+	fmt.Printf("%s\n", green("✓ All shims are in place"))
+}
+
+func handleShimsRepair() {
+	config := loadConfig()
+	dropped, removed, err := repairShims(config)
+	if err != nil {
+		log.Fatal(red("Error repairing shims: "), err)
+	}
+	displayRepairResult(dropped, removed)
+}
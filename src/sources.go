@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Names of the interpreter sources that can be listed in Config.Sources
+const (
+	sourceHomebrew  = "homebrew"
+	sourceUV        = "uv"
+	sourcePythonOrg = "python.org"
+	sourceSystem    = "system"
+)
+
+// interpreterSource discovers Python interpreters from one origin
+type interpreterSource interface {
+	Name() string
+	Discover(config Config) ([]Interpreter, error)
+}
+
+// warnedSources remembers the unknown source names already warned about, as
+// some commands scan for interpreters more than once
+var warnedSources = map[string]bool{}
+
+// getSources returns the sources to scan: Homebrew first, then any extra
+// sources enabled in the config
+func getSources(config Config) []interpreterSource {
+	available := map[string]interpreterSource{
+		sourceUV:        uvSource{},
+		sourcePythonOrg: pythonOrgSource{},
+		sourceSystem:    systemSource{},
+	}
+
+	sources := []interpreterSource{homebrewSource{}}
+	for _, name := range config.Sources {
+		if source, ok := available[name]; ok {
+			sources = append(sources, source)
+			delete(available, name)
+		} else if !slices.Contains([]string{sourceHomebrew, sourceUV, sourcePythonOrg, sourceSystem}, name) && !warnedSources[name] {
+			// A typo would otherwise just make interpreters disappear
+			warnedSources[name] = true
+			fmt.Fprintf(os.Stderr, "%s Unknown interpreter source %q in the config; the known sources are %s, %s and %s\n", yellow("Warning:"), name, sourceUV, sourcePythonOrg, sourceSystem)
+		}
+	}
+	return sources
+}
+
+// withSystemSource adds the system interpreters unless the system source is
+// enabled and they were scanned already
+func withSystemSource(config Config, versions []Interpreter) []Interpreter {
+	if slices.Contains(config.Sources, sourceSystem) {
+		return versions
+	}
+	found, err := systemSource{}.Discover(config)
+	if err != nil {
+		return versions
+	}
+	return append(versions, found...)
+}
+
+// homebrewSource finds the kegs in every configured Homebrew prefix
+type homebrewSource struct{}
+
+func (homebrewSource) Name() string { return sourceHomebrew }
+
+func (homebrewSource) Discover(config Config) ([]Interpreter, error) {
+	var versions []Interpreter
+	var lastErr error
+	scanned := 0
+
+	for _, prefix := range getBrewPrefixes(config) {
+		found, err := scanPrefix(prefix.Path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		scanned++
+
+		for _, interp := range found {
+			interp.Source = sourceHomebrew
+			versions = append(versions, interp)
+		}
+	}
+
+	if scanned == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return versions, nil
+}
+
+// uvSource finds interpreters installed with `uv python install`
+type uvSource struct{}
+
+// regex to match uv install directories like cpython-3.12.4-macos-aarch64-none
+// or cpython-3.13.0+freethreaded-macos-aarch64-none
+var uvDirRe = regexp.MustCompile(`^cpython-(\d+\.\d+\.\d+(?:(?:a|b|rc)\d+)?)(\+freethreaded)?-[^-]+-([^-]+)-`)
+
+func (uvSource) Name() string { return sourceUV }
+
+func (uvSource) Discover(config Config) ([]Interpreter, error) {
+	installDir := getUVPythonDir()
+	entries, err := os.ReadDir(installDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Interpreter
+	for _, entry := range entries {
+		matches := uvDirRe.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := ParseVersion(matches[1])
+		if err != nil {
+			continue
+		}
+		if matches[2] != "" {
+			version.Variant = "t"
+		}
+
+		dir := filepath.Join(installDir, entry.Name())
+		path := filepath.Join(dir, "bin", "python"+version.MinorString())
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		interp := newInterpreter(version, dir, path)
+		interp.Source = sourceUV
+		if binaryArch(path) == "" {
+			interp.Arch = normalizeArch(matches[3])
+		}
+		versions = append(versions, interp)
+	}
+	return versions, nil
+}
+
+// getUVPythonDir returns where uv keeps its managed interpreters
+func getUVPythonDir() string {
+	if dir := os.Getenv("UV_PYTHON_INSTALL_DIR"); dir != "" {
+		return expandPath(dir)
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "uv", "python")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".local", "share", "uv", "python")
+}
+
+// normalizeArch maps the arch names used by other tools to BrewPy's labels
+func normalizeArch(arch string) string {
+	switch arch {
+	case "aarch64":
+		return "arm64"
+	case "amd64":
+		return "x86_64"
+	}
+	return arch
+}
+
+// pythonOrgSource finds framework builds from the python.org installers
+type pythonOrgSource struct{}
+
+// regex to read the patch version from a framework's Info.plist
+var bundleVersionRe = regexp.MustCompile(`<key>CFBundleVersion</key>\s*<string>([^<]+)</string>`)
+
+func (pythonOrgSource) Name() string { return sourcePythonOrg }
+
+func (pythonOrgSource) Discover(config Config) ([]Interpreter, error) {
+	var versions []Interpreter
+
+	// PythonT.framework holds the free-threaded builds
+	for _, framework := range []string{"Python.framework", "PythonT.framework"} {
+		versionsDir := filepath.Join("/Library/Frameworks", framework, "Versions")
+		entries, err := os.ReadDir(versionsDir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			minor, err := ParseVersion(entry.Name())
+			if err != nil || entry.Name() == "Current" {
+				continue
+			}
+			if framework == "PythonT.framework" {
+				minor.Variant = "t"
+			}
+
+			dir := filepath.Join(versionsDir, entry.Name())
+			path := filepath.Join(dir, "bin", "python"+minor.MinorString())
+			if _, err := os.Lstat(path); err != nil {
+				continue
+			}
+
+			version := minor
+			if plist, err := os.ReadFile(filepath.Join(dir, "Resources", "Info.plist")); err == nil {
+				if m := bundleVersionRe.FindSubmatch(plist); m != nil {
+					if parsed, err := ParseVersion(string(m[1])); err == nil {
+						parsed.Variant = minor.Variant
+						version = parsed
+					}
+				}
+			}
+
+			interp := newInterpreter(version, dir, path)
+			interp.Source = sourcePythonOrg
+			versions = append(versions, interp)
+		}
+	}
+
+	return versions, nil
+}
+
+// systemSource finds the interpreter that ships with the OS: the Command
+// Line Tools python on macOS, /usr/bin on Linux
+type systemSource struct{}
+
+func (systemSource) Name() string { return sourceSystem }
+
+func (systemSource) Discover(config Config) ([]Interpreter, error) {
+	// /usr/bin/python3 on macOS is a stub that offers to install the Command
+	// Line Tools, so only the real interpreter inside them is listed
+	binDir := "/usr/bin"
+	if runtime.GOOS == "darwin" {
+		binDir = "/Library/Developer/CommandLineTools/usr/bin"
+	}
+
+	files, err := os.ReadDir(binDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Interpreter
+	for _, file := range files {
+		matches := pythonBinRe.FindStringSubmatch(file.Name())
+		if len(matches) != 2 {
+			continue
+		}
+		version, err := ParseVersion(matches[1])
+		if err != nil {
+			continue
+		}
+
+		interp := newInterpreter(version, strings.TrimSuffix(binDir, "/bin"), filepath.Join(binDir, file.Name()))
+		interp.Source = sourceSystem
+		versions = append(versions, interp)
+	}
+	return versions, nil
+}
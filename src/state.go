@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// selectedVersion identifies an interpreter chosen with `brewpy use`
+type selectedVersion struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Prefix  string `json:"prefix"`
+	Arch    string `json:"arch"`
+}
+
+// State records the selection made by the last `brewpy use`. The primary
+// version gets the whole shim set; secondary versions only get versioned
+// shims such as python3.11.
+type State struct {
+	selectedVersion
+	Secondary  []selectedVersion `json:"secondary,omitempty"`
+	Mode       string            `json:"mode"`
+	SelectedAt time.Time         `json:"selected_at"`
+	Shims      map[string]string `json:"shims"`
+	Versioned  map[string]string `json:"versioned,omitempty"`
+	Scripts    map[string]string `json:"scripts,omitempty"`
+	// BrewPrefix is the Homebrew prefix detected at the time, so shims don't
+	// have to run `brew --prefix` on every call
+	BrewPrefix string `json:"brew_prefix,omitempty"`
+}
+
+func selectVersion(version Interpreter) selectedVersion {
+	return selectedVersion{
+		Version: version.FullName(),
+		Source:  version.Source,
+		Prefix:  version.Prefix,
+		Arch:    version.Arch,
+	}
+}
+
+// Spec returns a version spec that resolves to exactly this interpreter
+func (s selectedVersion) Spec() string {
+	return s.Version + "@" + s.Prefix
+}
+
+// Label describes the selection the same way Interpreter.Label does
+func (s selectedVersion) Label() string {
+	if s.Source != "" && s.Source != sourceHomebrew {
+		return fmt.Sprintf("%s (%s, %s: %s)", s.Version, s.Arch, s.Source, s.Prefix)
+	}
+	return fmt.Sprintf("%s (%s, %s)", s.Version, s.Arch, s.Prefix)
+}
+
+// find returns the installed interpreter this selection refers to
+func (s selectedVersion) find(versions []Interpreter) (Interpreter, bool) {
+	version, err := resolveVersion(versions, s.Spec())
+	if err != nil || version.FullName() != s.Version {
+		return Interpreter{}, false
+	}
+	return version, true
+}
+
+// findSeries returns the best installed interpreter of the same minor series
+// on the same prefix, for a selection whose exact patch has been upgraded away
+func (s selectedVersion) findSeries(versions []Interpreter) (Interpreter, bool) {
+	parsed, _, err := s.parse()
+	if err != nil {
+		return Interpreter{}, false
+	}
+	spec := fmt.Sprintf("%d.%d%s@%s", parsed.Major, parsed.Minor, parsed.Variant, s.Prefix)
+	version, err := resolveVersion(versions, spec)
+	if err != nil || (s.Source != "" && version.Source != s.Source) {
+		return Interpreter{}, false
+	}
+	return version, true
+}
+
+// resolve finds this selection among the installed versions, falling back to
+// its successor in the same minor series when the exact patch is gone
+func (s selectedVersion) resolve(versions []Interpreter) (Interpreter, bool) {
+	if version, ok := s.find(versions); ok {
+		return version, true
+	}
+	return s.findSeries(versions)
+}
+
+// parse splits the recorded name into its version and Homebrew revision
+func (s selectedVersion) parse() (Version, int, error) {
+	name, revision := s.Version, 0
+	if m := revisionRe.FindStringSubmatch(name); m != nil {
+		name = m[1]
+		revision, _ = strconv.Atoi(m[2])
+	}
+	parsed, err := ParseVersion(name)
+	return parsed, revision, err
+}
+
+// getStatePath returns the path to the state file based on BrewPyDir
+func getStatePath(brewPyDir string) string {
+	return filepath.Join(brewPyDir, "state.json")
+}
+
+// loadState reads the state file. The bool is false when no selection has
+// been recorded yet.
+func loadState(config Config) (State, bool, error) {
+	data, err := os.ReadFile(getStatePath(config.BrewPyDir))
+	if os.IsNotExist(err) {
+		return State{}, false, nil
+	}
+	if err != nil {
+		return State{}, false, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, false, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return state, true, nil
+}
+
+func saveState(config Config, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := writeFileAtomic(getStatePath(config.BrewPyDir), data, 0644, nil); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// newState builds the state for a selection and the shims created for it
+func newState(config Config, version Interpreter, secondary []Interpreter, plan shimPlan) State {
+	brewPrefix, _ := detectBrewPrefix(config)
+	state := State{
+		selectedVersion: selectVersion(version),
+		BrewPrefix:      brewPrefix,
+		Mode:            getShimMode(config),
+		SelectedAt:      time.Now(),
+		Shims:           plan.Shims,
+		Versioned:       plan.Versioned,
+		Scripts:         plan.Scripts,
+	}
+	for _, v := range secondary {
+		state.Secondary = append(state.Secondary, selectVersion(v))
+	}
+	return state
+}
+
+// Interpreter finds the recorded selection among the installed versions.
+// After a patch upgrade the recorded version is gone but the python shim's
+// bin or opt link leads to its successor, which is what the shims run. If
+// neither is installed, an Interpreter is rebuilt from the state alone so it
+// can still be reported.
+func (s State) Interpreter(versions []Interpreter) Interpreter {
+	if version, ok := s.find(versions); ok {
+		return version
+	}
+	if target, ok := s.Shims["python"]; ok {
+		if version, ok := findByTarget(versions, target); ok {
+			return version
+		}
+	}
+
+	parsed, revision, _ := s.parse()
+
+	interp := Interpreter{Version: parsed, Revision: revision, Source: s.Source, Prefix: s.Prefix, Arch: s.Arch}
+	if target, ok := s.Shims["python"]; ok {
+		interp.BinDir = filepath.Dir(target)
+	}
+	return interp
+}
+
+// AllShims returns every shim BrewPy manages: the shim set, the versioned
+// shims and the console scripts added by rehash
+func (s State) AllShims() map[string]string {
+	all := map[string]string{}
+	for name, target := range s.Scripts {
+		all[name] = target
+	}
+	for name, target := range s.Versioned {
+		all[name] = target
+	}
+	for name, target := range s.Shims {
+		all[name] = target
+	}
+	return all
+}
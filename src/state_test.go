@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestSelectedVersionFindSeries(t *testing.T) {
+	versions := []Interpreter{
+		testInterpreter("3.11.9_1", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.11.9", sourceHomebrew, "/usr/local", "x86_64"),
+		testInterpreter("3.13.1t", sourceHomebrew, "/opt/homebrew", "arm64"),
+		testInterpreter("3.13.2", sourceHomebrew, "/opt/homebrew", "arm64"),
+	}
+
+	tests := []struct {
+		selected selectedVersion
+		want     string // Label of the chosen interpreter, empty for none
+	}{
+		{selectedVersion{"Python3.11.4", sourceHomebrew, "/opt/homebrew", "arm64"}, "Python3.11.9_1 (arm64, /opt/homebrew)"},
+		{selectedVersion{"Python3.11.8_2", sourceHomebrew, "/usr/local", "x86_64"}, "Python3.11.9 (x86_64, /usr/local)"},
+		{selectedVersion{"Python3.13.1", sourceHomebrew, "/opt/homebrew", "arm64"}, "Python3.13.2 (arm64, /opt/homebrew)"},
+		{selectedVersion{"Python3.13.0t", sourceHomebrew, "/opt/homebrew", "arm64"}, "Python3.13.1t (arm64, /opt/homebrew)"},
+		{selectedVersion{"Python3.12.4", sourceHomebrew, "/opt/homebrew", "arm64"}, ""},
+		{selectedVersion{"Python3.11.4", sourceUV, "/opt/homebrew", "arm64"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selected.Label(), func(t *testing.T) {
+			got, ok := tt.selected.findSeries(versions)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("findSeries() = %s, want no match", got.Label())
+				}
+				return
+			}
+			if !ok || got.Label() != tt.want {
+				t.Errorf("findSeries() = %s, %v; want %s", got.Label(), ok, tt.want)
+			}
+		})
+	}
+}
@@ -2,49 +2,374 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
-func createSymlinks(version string) error {
+// Shim modes that can be set in Config.ShimMode
+const (
+	shimModeSymlink = "symlink"
+	shimModeScript  = "script"
+)
+
+// getShimMode returns the configured shim mode, defaulting to symlinks
+func getShimMode(config Config) string {
+	if config.ShimMode == shimModeScript {
+		return shimModeScript
+	}
+	return shimModeSymlink
+}
+
+// Link-target policies that can be set in Config.LinkTarget. They decide which
+// path to a Homebrew keg's executables the shims use:
+//
+//	bin:    $prefix/bin/python3.11, relinked by Homebrew on every upgrade
+//	opt:    $prefix/opt/python@3.11/bin/python3.11, stable across patch upgrades
+//	cellar: $prefix/Cellar/python@3.11/3.11.9/bin/python3.11, pinned to one patch
+const (
+	linkTargetBin    = "bin"
+	linkTargetOpt    = "opt"
+	linkTargetCellar = "cellar"
+)
+
+// getLinkTarget returns the configured link-target policy, defaulting to bin
+func getLinkTarget(config Config) string {
+	switch config.LinkTarget {
+	case linkTargetOpt, linkTargetCellar:
+		return config.LinkTarget
+	}
+	return linkTargetBin
+}
+
+// linkDir returns the directory the shims for version link into under the
+// configured policy. Only the linked keg can be reached through opt, so other
+// kegs fall back to their Cellar path. Interpreters that don't come from a
+// Homebrew keg always use their own bin dir.
+func linkDir(config Config, version Interpreter) string {
+	if version.Keg == "" {
+		return version.BinDir
+	}
+
+	switch getLinkTarget(config) {
+	case linkTargetOpt:
+		if version.Linked {
+			return filepath.Join(version.Prefix, "opt", filepath.Base(filepath.Dir(version.Keg)), "bin")
+		}
+		return filepath.Join(version.Keg, "bin")
+	case linkTargetCellar:
+		return filepath.Join(version.Keg, "bin")
+	}
+	return version.BinDir
+}
+
+// defaultShims maps shim names to the versioned executables Homebrew
+// installs for each Python, with {version} standing for e.g. "3.11"
+var defaultShims = map[string]string{
+	"python":         "python{version}",
+	"python3":        "python{version}",
+	"pip":            "pip{version}",
+	"pip3":           "pip{version}",
+	"idle3":          "idle{version}",
+	"pydoc3":         "pydoc{version}",
+	"python3-config": "python{version}-config",
+	"2to3":           "2to3-{version}",
+	"wheel":          "wheel{version}",
+	"wheel3":         "wheel{version}",
+}
+
+// getShimSet returns the shim definitions: the defaults with the config's
+// entries layered on top. An empty template removes a default shim.
+func getShimSet(config Config) map[string]string {
+	shims := map[string]string{}
+	for name, template := range defaultShims {
+		shims[name] = template
+	}
+	for name, template := range config.Shims {
+		if template == "" {
+			delete(shims, name)
+		} else {
+			shims[name] = template
+		}
+	}
+	return shims
+}
+
+// shimTargets maps each shim name to the executable it runs for version
+func shimTargets(config Config, version Interpreter) map[string]string {
+	replacer := strings.NewReplacer(
+		"{version}", version.MinorVersion(),
+		"{major}", fmt.Sprintf("%d", version.Version.Major),
+	)
+
+	dir := linkDir(config, version)
+	targets := map[string]string{}
+	for name, template := range getShimSet(config) {
+		targets[name] = filepath.Join(dir, replacer.Replace(template))
+	}
+	return targets
+}
+
+// shimPlan is the complete set of shims for one selection
+type shimPlan struct {
+	Shims     map[string]string // shim set entries, name -> executable
+	Versioned map[string]string // pythonX.Y and pipX.Y for every selected version
+	Scripts   map[string]string // console scripts, name -> script
+	Missing   []string          // shims whose executable isn't installed
+}
+
+// All returns every shim in the plan, by name
+func (p shimPlan) All() map[string]string {
+	all := map[string]string{}
+	for name, target := range p.Scripts {
+		all[name] = target
+	}
+	for name, target := range p.Versioned {
+		all[name] = target
+	}
+	for name, target := range p.Shims {
+		all[name] = target
+	}
+	return all
+}
+
+// planShims works out the shims for version: the shim set entries it ships
+// and the console scripts installed for it. Versioned shims are added for it
+// and for each secondary version.
+func planShims(config Config, version Interpreter, secondary []Interpreter) shimPlan {
+	plan := shimPlan{Shims: map[string]string{}, Versioned: map[string]string{}, Scripts: map[string]string{}}
+
+	for linkName, target := range shimTargets(config, version) {
+		// Skip executables this version doesn't ship rather than leave a
+		// dangling shim
+		if _, err := os.Stat(target); err != nil {
+			plan.Missing = append(plan.Missing, fmt.Sprintf("%s (%s)", linkName, filepath.Base(target)))
+			continue
+		}
+		plan.Shims[linkName] = target
+	}
+
+	for _, v := range append([]Interpreter{version}, secondary...) {
+		minor := v.MinorVersion()
+		dir := linkDir(config, v)
+		for _, name := range []string{"python" + minor, "pip" + minor} {
+			if _, ok := plan.Shims[name]; ok {
+				continue
+			}
+			target := filepath.Join(dir, name)
+			if _, err := os.Stat(target); err != nil {
+				plan.Missing = append(plan.Missing, fmt.Sprintf("%s (%s)", name, v.FullName()))
+				continue
+			}
+			plan.Versioned[name] = target
+		}
+	}
+	sort.Strings(plan.Missing)
+
+	cache := loadInfoCache(config)
+	if info, err := cache.Lookup(version); err == nil {
+		plan.Scripts = findEntryPoints(config, version, info)
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
+
+	return plan
+}
+
+// createSymlinks points the shims at version, adds versioned shims for the
+// secondary versions and returns the plan it installed. In script mode the
+// shims are launchers that resolve the version on every run, and the targets
+// are only recorded for the global selection.
+func createSymlinks(version Interpreter, secondary []Interpreter) (shimPlan, error) {
 	config := loadConfig()
-	
-	shimsPath := getShimsDir(config.BrewPyDir)
 
-	// Create shims directory if it doesn't exist (though initConfig should have done this)
-	err := os.MkdirAll(shimsPath, 0755)
+	plan := planShims(config, version, secondary)
+	if err := installShims(config, getShimMode(config), plan.All()); err != nil {
+		return shimPlan{}, err
+	}
+	return plan, nil
+}
+
+// installShims swaps the global shims directory over to a new generation
+func installShims(config Config, mode string, shims map[string]string) error {
+	return installGeneration(getShimsDir(config.BrewPyDir), getShimsStoreDir(config.BrewPyDir), mode, shims)
+}
+
+// installGeneration writes shims into a new generation under storePath and
+// swaps the link at shimsPath over to it, so whoever has shimsPath on PATH
+// sees either the old set or the new one and never a partial one
+func installGeneration(shimsPath, storePath, mode string, shims map[string]string) error {
+	if err := os.MkdirAll(storePath, 0755); err != nil {
+		return fmt.Errorf("failed to create shims store: %w", err)
+	}
+
+	generation, err := os.MkdirTemp(storePath, "gen-")
 	if err != nil {
+		return fmt.Errorf("failed to create shims generation: %w", err)
+	}
+	if err := os.Chmod(generation, 0755); err != nil {
+		os.RemoveAll(generation)
+		return fmt.Errorf("failed to create shims generation: %w", err)
+	}
+
+	for name, target := range shims {
+		if err := writeShim(generation, name, target, mode); err != nil {
+			os.RemoveAll(generation)
+			return err
+		}
+	}
+
+	previous, _ := os.Readlink(shimsPath)
+	if err := swapShimsLink(shimsPath, generation); err != nil {
+		os.RemoveAll(generation)
 		return err
 	}
 
-	// Extract version number from "Python3.11" -> "3.11"
-	ver := strings.TrimPrefix(version, "Python")
-	binDir := getBinDir()
+	// Only the generation we replaced is removed, in case another brewpy is
+	// building one right now
+	if previous != "" && filepath.Dir(previous) == storePath && previous != generation {
+		os.RemoveAll(previous)
+	}
+	return nil
+}
 
-	// Define the symlinks to create
-	links := map[string]string{
-		"python":  filepath.Join(binDir, "python"+ver),
-		"python3": filepath.Join(binDir, "python"+ver),
-		"pip":     filepath.Join(binDir, "pip"+ver),
-		"pip3":    filepath.Join(binDir, "pip"+ver),
+// swapShimsLink atomically points the shims link at generation
+func swapShimsLink(shimsPath, generation string) error {
+	tmpLink := fmt.Sprintf("%s.tmp-%d", shimsPath, os.Getpid())
+	os.Remove(tmpLink)
+	if err := os.Symlink(generation, tmpLink); err != nil {
+		return fmt.Errorf("failed to create shims link: %w", err)
 	}
 
-	// Remove existing symlinks and create new ones
-	for linkName, target := range links {
-		linkPath := filepath.Join(shimsPath, linkName)
+	// Shims from before generations existed live in a plain directory, which
+	// rename can't replace. Move it aside once; this first switch can't be atomic.
+	legacy := ""
+	if info, err := os.Lstat(shimsPath); err == nil && info.IsDir() {
+		legacy = fmt.Sprintf("%s.old-%d", shimsPath, os.Getpid())
+		if err := os.Rename(shimsPath, legacy); err != nil {
+			os.Remove(tmpLink)
+			return fmt.Errorf("failed to move old shims directory aside: %w", err)
+		}
+	}
 
-		// Remove existing symlink if it exists
-		if _, err := os.Lstat(linkPath); err == nil {
-			os.Remove(linkPath)
+	if err := os.Rename(tmpLink, shimsPath); err != nil {
+		os.Remove(tmpLink)
+		// Put the old shims back so a failed switch leaves them in place
+		if legacy != "" {
+			os.Rename(legacy, shimsPath)
 		}
+		return fmt.Errorf("failed to switch shims: %w", err)
+	}
+
+	if legacy != "" {
+		os.RemoveAll(legacy)
+	}
+	return nil
+}
+
+// getShellShimsDir returns the directory of symlink shims `brewpy shell` puts
+// on PATH for version
+func getShellShimsDir(config Config, version Interpreter) string {
+	return filepath.Join(config.BrewPyDir, "shells.d", url.PathEscape(selectVersion(version).Spec()))
+}
+
+// installShellShims builds the whole shim set for version in a directory of
+// its own. Symlink shims can't read $BREWPY_VERSION, so `brewpy shell` puts
+// this directory ahead of the global shims on the shell's PATH instead. Like
+// the global shims it's a link to a generation, since other shells may have
+// it on PATH while it's rebuilt.
+func installShellShims(config Config, version Interpreter, versions []Interpreter) (string, error) {
+	dir := getShellShimsDir(config, version)
+	store := filepath.Join(filepath.Dir(dir), ".generations")
+	if err := installGeneration(dir, store, shimModeSymlink, planShims(config, version, nil).All()); err != nil {
+		return "", err
+	}
+
+	pruneShellShims(config, versions)
+	return dir, nil
+}
 
-		// Create new symlink
-		err = os.Symlink(target, linkPath)
-		if err != nil {
-			return fmt.Errorf("failed to create symlink %s -> %s: %w", linkPath, target, err)
+// pruneShellShims removes the shell shim sets of versions that are no longer
+// installed, e.g. after a patch upgrade. Shells still using one were already
+// running an interpreter that's gone. The system interpreters count as
+// installed even when the system source is off, since `brewpy shell system`
+// reaches them anyway.
+func pruneShellShims(config Config, versions []Interpreter) {
+	base := filepath.Join(config.BrewPyDir, "shells.d")
+	installed := map[string]bool{".generations": true}
+	for _, v := range withSystemSource(config, versions) {
+		installed[filepath.Base(getShellShimsDir(config, v))] = true
+	}
+
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		// Leave links another brewpy is swapping in right now alone
+		if installed[name] || strings.Contains(name, ".tmp-") || strings.Contains(name, ".old-") {
+			continue
+		}
+
+		path := filepath.Join(base, name)
+		if generation, err := os.Readlink(path); err == nil && filepath.Dir(generation) == filepath.Join(base, ".generations") {
+			os.RemoveAll(generation)
 		}
+		os.RemoveAll(path)
+	}
+}
+
+// ensureShimsDir makes sure the shims link exists, pointing at an empty
+// generation if nothing has been selected yet
+func ensureShimsDir(config Config) error {
+	if _, err := os.Stat(getShimsDir(config.BrewPyDir)); err == nil {
+		return nil
 	}
+	return installShims(config, getShimMode(config), nil)
+}
 
+// writeShim creates the shim called name in dir: a symlink to target, or a
+// launcher script in script mode
+func writeShim(dir, name, target, mode string) error {
+	linkPath := filepath.Join(dir, name)
+
+	if mode == shimModeScript {
+		if err := os.WriteFile(linkPath, []byte(launcherScript(name)), 0755); err != nil {
+			return fmt.Errorf("failed to write shim %s: %w", linkPath, err)
+		}
+		return nil
+	}
+
+	// Create new symlink
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to create symlink %s -> %s: %w", linkPath, target, err)
+	}
 	return nil
-} 
\ No newline at end of file
+}
+
+// launcherScript returns the script shim for name. It hands off to
+// `brewpy exec`, which works out the version and replaces itself with the
+// right interpreter.
+func launcherScript(name string) string {
+	return fmt.Sprintf("#!/bin/sh\n# Generated by brewpy; resolves the Python version on every run\nexec \"%s\" exec %s \"$@\"\n", getBrewPyExecutable(), name)
+}
+
+// getBrewPyExecutable returns a path to the brewpy binary that stays valid
+// across upgrades: the Homebrew bin link rather than the versioned Cellar path
+func getBrewPyExecutable() string {
+	exe, err := os.Executable()
+	if err != nil || strings.Contains(exe, "/Cellar/") {
+		if path, err := exec.LookPath("brewpy"); err == nil {
+			return path
+		}
+	}
+	if err != nil {
+		return "brewpy"
+	}
+	return exe
+}
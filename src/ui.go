@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
@@ -19,53 +20,260 @@ var (
 func showUsage() {
 	fmt.Printf(`%s
   %s - list installed python versions
-  %s - set python version (e.g. Python3.11). If no version given, prompts selection
-  %s - output shell configuration
+  %s - set python version (e.g. 3.12, 3, latest, previous, ">=3.10,<3.13" or 3.11@x86_64). Extra versions only get versioned shims such as python3.11. If no version given, prompts selection. Broken versions are refused unless --force is given. "-" returns to the previous version and @{n} goes n switches back
+  %s - list past version switches
+  %s - set the version for this directory in .python-version (--unset removes it)
+  %s - set the version for this shell only (--unset clears it)
+  %s - create shims for console scripts installed with pip (runs after use)
+  %s - show each shim and its target, check them (exits non-zero on problems), or recreate them from the recorded selection
+  %s - output shell configuration for zsh, bash, fish, nu, pwsh, xonsh or elvish (detected if omitted)
+  %s - run a command with the version selected for this directory
   %s - show currently active python version
+  %s - show details about a python version (defaults to the current one)
+  %s - restore an rc file from the backups BrewPy takes before editing it (prompts if no file given)
   %s - configure BrewPy settings interactively
   %s - show current BrewPy configuration
 `,
 		bold("🍺 BrewPy - Homebrew Python Version Manager"),
 		cyan("brewpy versions"),
-		cyan("brewpy use [version]"),
-		cyan("brewpy init"),
+		cyan("brewpy use [--force] [version...]"),
+		cyan("brewpy history"),
+		cyan("brewpy local [version]"),
+		cyan("brewpy shell [version]"),
+		cyan("brewpy rehash"),
+		cyan("brewpy shims [list|verify|repair]"),
+		cyan("brewpy init [shell]"),
+		cyan("brewpy exec <command> [args]"),
 		cyan("brewpy current"),
+		cyan("brewpy info [version]"),
+		cyan("brewpy restore-rc [file]"),
 		cyan("brewpy config"),
 		cyan("brewpy config show"),
 	)
 }
 
+func displayShellDetection(config Config, detection shellDetection) {
+	if detection.Shell != nil {
+		fmt.Printf("  Detected shell %s because %s\n", detection.Shell.Name(), detection.ShellWhy)
+	} else {
+		fmt.Printf("  No shell detected because %s\n", detection.ShellWhy)
+	}
+	fmt.Printf("  Detected %s because %s\n", detection.RC, detection.RCWhy)
+	if detection.RC != config.ShellRC {
+		fmt.Printf("  %s The config file sets a different rc file; 'brewpy config' can switch to the detected one\n", yellow("Note:"))
+	}
+}
+
 func displayVersionsHeader() {
 	fmt.Printf("%s\n", bold("🔍 Available Python Versions:"))
 }
 
-func displayVersionsList(versions []string, current string) {
+func displayVersionsList(versions []Interpreter, current Interpreter, cache *infoCache) {
 	for _, v := range versions {
-		if v == current {
-			fmt.Printf("  %s %s\n", green("●"), green(v))
+		notes := ""
+		if v.Linked {
+			notes += " " + cyan("[opt]")
+		}
+		problems := checkHealth(v, cache)
+		if hasErrors(problems) {
+			notes += " " + red("(broken)")
+		} else if info, err := cache.Lookup(v); err == nil && info.Implementation != "cpython" {
+			notes += " " + yellow(info.Implementation)
+		}
+
+		if v.Matches(current) {
+			fmt.Printf("  %s %s%s\n", green("●"), green(v.Label()), notes)
+		} else {
+			fmt.Printf("  %s %s%s\n", "○", v.Label(), notes)
+		}
+		displayHealthProblems(problems, "    ")
+	}
+}
+
+func displayHealthProblems(problems []healthProblem, indent string) {
+	for _, problem := range problems {
+		if problem.Severity == severityError {
+			fmt.Printf("%s%s %s\n", indent, red("✗"), problem.Message)
 		} else {
-			fmt.Printf("  %s %s\n", "○", v)
+			fmt.Printf("%s%s %s\n", indent, yellow("⚠"), problem.Message)
 		}
 	}
 }
 
-func displayCurrentVersion(current string) {
-	if current == "" {
+func displayCurrentVersion(current Interpreter, ok bool) {
+	if !ok {
 		fmt.Printf("%s\n", yellow("No Python version currently managed by BrewPy"))
 	} else {
-		fmt.Printf("%s %s\n", green("Current Python version:"), green(current))
+		fmt.Printf("%s %s\n", green("Current Python version:"), green(current.Label()))
+	}
+}
+
+func displayOrigin(sel selection) {
+	fmt.Printf("  Set by:        %s\n", sel.Origin)
+}
+
+func displaySecondaryVersions(state State) {
+	for i, s := range state.Secondary {
+		if i == 0 {
+			fmt.Printf("  Also selected: %s\n", s.Label())
+		} else {
+			fmt.Printf("                 %s\n", s.Label())
+		}
+	}
+}
+
+func displaySelectedAt(state State) {
+	fmt.Printf("  Selected:      %s\n", state.SelectedAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+func displayDrift(drift []string) {
+	if len(drift) == 0 {
+		return
+	}
+
+	fmt.Printf("%s The shims no longer match the recorded selection:\n", yellow("Warning:"))
+	for _, problem := range drift {
+		fmt.Printf("  %s %s\n", yellow("⚠"), problem)
+	}
+	fmt.Printf("Run 'brewpy shims repair' to recreate them.\n")
+}
+
+func displayShimList(shimsPath string, statuses []shimStatus) {
+	fmt.Printf("%s %s\n", bold("🔗 Shims in"), bold(shimsPath))
+	for _, status := range statuses {
+		target := status.Target
+		if target == "" {
+			target = "(not a link)"
+		}
+
+		if status.Problem == "" {
+			fmt.Printf("  %s %s → %s\n", green("✓"), status.Name, target)
+		} else if status.Managed {
+			fmt.Printf("  %s %s → %s %s\n", red("✗"), status.Name, target, red("("+status.Problem+")"))
+		} else {
+			fmt.Printf("  %s %s → %s %s\n", yellow("?"), status.Name, target, yellow("("+status.Problem+")"))
+		}
+	}
+}
+
+func displayRepairResult(dropped, removed []string) {
+	if len(dropped) > 0 {
+		fmt.Printf("%s %s\n", yellow("Dropped shims the selection no longer provides:"), strings.Join(dropped, ", "))
+	}
+	if len(removed) > 0 {
+		fmt.Printf("%s %s\n", yellow("Removed entries not created by BrewPy:"), strings.Join(removed, ", "))
+	}
+	fmt.Printf("%s\n", green("✓ Shims recreated from the recorded selection"))
+}
+
+func displayCurrentInfo(info InterpreterInfo) {
+	fmt.Printf("  Site-packages: %s\n", info.Paths["purelib"])
+	fmt.Printf("  Scripts:       %s\n", info.Paths["scripts"])
+}
+
+func displayInterpreterInfo(version Interpreter, info InterpreterInfo) {
+	fmt.Printf("%s\n", bold("🐍 "+version.FullName()))
+	fmt.Printf("  Executable:      %s\n", version.Executable())
+	fmt.Printf("  Prefix:          %s (%s)\n", version.Prefix, version.Arch)
+	if version.Keg != "" {
+		fmt.Printf("  Keg:             %s\n", version.Keg)
+	}
+	fmt.Printf("  sys.version:     %s\n", info.Version)
+	fmt.Printf("  Implementation:  %s\n", info.Implementation)
+	fmt.Printf("  Platform:        %s\n", info.Platform)
+	fmt.Printf("  Stdlib:          %s\n", info.Paths["stdlib"])
+	fmt.Printf("  Site-packages:   %s\n", info.Paths["purelib"])
+	fmt.Printf("  Scripts:         %s\n", info.Paths["scripts"])
+	fmt.Printf("  User scripts:    %s\n", info.UserScripts)
+	fmt.Printf("  Include:         %s\n", info.Paths["include"])
+	if info.ExternallyManaged {
+		fmt.Printf("  %s\n", yellow("Externally managed (PEP 668): use a virtualenv or pip --user"))
+	} else {
+		fmt.Printf("  Not externally managed\n")
+	}
+}
+
+func displayHistory(history []historyEntry) {
+	fmt.Printf("%s\n", bold("🕘 Switch History:"))
+	for n := 0; n < len(history); n++ {
+		entry := history[len(history)-1-n]
+		ref := fmt.Sprintf("@{%d}", n)
+		label := entry.Label()
+		for _, s := range entry.Secondary {
+			label += ", " + s.Version
+		}
+		when := entry.SwitchedAt.Local().Format("2006-01-02 15:04:05")
+		if n == 0 {
+			fmt.Printf("  %s %s  %s\n", green(fmt.Sprintf("%-6s", ref)), when, green(label))
+		} else {
+			fmt.Printf("  %-6s %s  %s\n", ref, when, label)
+		}
+	}
+}
+
+func displayMissingShims(missing []string) {
+	if len(missing) == 0 {
+		return
 	}
+	fmt.Printf("%s These shims were skipped because their executable isn't installed: %s\n", yellow("Note:"), strings.Join(missing, ", "))
 }
 
-func displaySuccessMessage(version string) {
-	fmt.Printf("%s %s\n", green("✓ Successfully switched to"), green(version))
-	fmt.Printf("%s\n", yellow("Restart your terminal or run 'source ~/.zshrc' to apply changes."))
+func displayRehashResult(added, removed []string) {
+	if len(added) > 0 {
+		fmt.Printf("%s %s\n", green("✓ Added shims for console scripts:"), strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		fmt.Printf("%s %s\n", yellow("Removed shims for uninstalled scripts:"), strings.Join(removed, ", "))
+	}
+}
+
+func displayDamagedBlock(damaged *damagedBlockError) {
+	fmt.Printf("%s %s was left unchanged: %s\n", yellow("Warning:"), damaged.Path, damaged.Problem)
+	fmt.Printf("BrewPy suggests this fix:\n")
+	fmt.Printf("--- %s\n+++ %s\n", damaged.Path, damaged.Path)
+	for _, line := range damaged.Diff {
+		switch line[0] {
+		case '-':
+			fmt.Printf("%s\n", red(line))
+		case '+':
+			fmt.Printf("%s\n", green(line))
+		case '@':
+			fmt.Printf("%s\n", cyan(line))
+		default:
+			fmt.Printf("%s\n", line)
+		}
+	}
+	fmt.Printf("Fix the markers by hand and run 'brewpy use' again to update it.\n")
 }
 
-func promptSelectVersion(versions []string) (string, error) {
+func displayRestoredBackup(backup rcBackup) {
+	fmt.Printf("%s %s from the backup taken %s\n", green("✓ Restored"), green(backup.Path), backup.TakenAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("The previous contents were backed up too; run 'brewpy restore-rc' to undo.\n")
+}
+
+func displaySuccessMessage(version Interpreter, secondary []Interpreter, profiles []string) {
+	fmt.Printf("%s %s\n", green("✓ Successfully switched to"), green(version.Label()))
+	for _, v := range secondary {
+		fmt.Printf("%s %s %s\n", green("✓ Also available as"), green("python"+v.MinorVersion()+":"), v.Label())
+	}
+	if len(profiles) > 0 {
+		fmt.Printf("%s %s\n", yellow("Restart your shell to apply changes; BrewPy is set up in"), strings.Join(profiles, ", "))
+	}
+}
+
+func promptSelectVersion(versions []Interpreter) (Interpreter, error) {
+	items := make([]string, len(versions))
+	for i, v := range versions {
+		items[i] = v.Label()
+		// Plain text, since the templates color the whole item
+		if v.Linked {
+			items[i] += " [opt]"
+		}
+	}
+
 	prompt := promptui.Select{
 		Label: fmt.Sprintf("%s Select Python Version", "🐍"),
-		Items: versions,
+		Items: items,
 		Size:  10,
 		Templates: &promptui.SelectTemplates{
 			Label:    "{{ . }}?",
@@ -75,6 +283,34 @@ func promptSelectVersion(versions []string) (string, error) {
 		},
 	}
 
-	_, result, err := prompt.Run()
-	return result, err
-} 
\ No newline at end of file
+	index, _, err := prompt.Run()
+	if err != nil {
+		return Interpreter{}, err
+	}
+	return versions[index], nil
+}
+
+func promptSelectBackup(backups []rcBackup) (rcBackup, error) {
+	items := make([]string, len(backups))
+	for i, b := range backups {
+		items[i] = fmt.Sprintf("%s  %s", b.TakenAt.Format("2006-01-02 15:04:05"), b.Path)
+	}
+
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("%s Select a backup to restore", "🗂"),
+		Items: items,
+		Size:  10,
+		Templates: &promptui.SelectTemplates{
+			Label:    "{{ . }}?",
+			Active:   fmt.Sprintf("%s {{ . | cyan }}", "▸"),
+			Inactive: "  {{ . }}",
+			Selected: fmt.Sprintf("%s {{ . | green }}", "✓"),
+		},
+	}
+
+	index, _, err := prompt.Run()
+	if err != nil {
+		return rcBackup{}, err
+	}
+	return backups[index], nil
+}
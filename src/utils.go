@@ -1,10 +0,0 @@
-package main
-
-func contains(arr []string, s string) bool {
-	for _, e := range arr {
-		if e == s {
-			return true
-		}
-	}
-	return false
-} 
\ No newline at end of file
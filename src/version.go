@@ -1,71 +1,349 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 )
 
-func getBinDir() string {
-	if runtime.GOARCH == "arm64" {
-		return "/opt/homebrew/bin"
+// Interpreter is a Python version found by one of the interpreter sources
+type Interpreter struct {
+	Version  Version // patch is -1 when only found in bin
+	Revision int     // Homebrew revision, e.g. 1 for a "3.11.9_1" keg
+	Source   string  // interpreterSource name, e.g. "homebrew" or "uv"
+	Prefix   string  // Homebrew prefix, or the install directory for other sources
+	Arch     string
+	BinDir   string
+	Keg      string // Cellar directory, empty when only found in bin
+	Linked   bool   // opt/python@X.Y points at this keg
+}
+
+// FullName returns the name with the exact version and revision, e.g.
+// "Python3.11.9_1", or "Python3.11" when the Cellar version is unknown
+func (i Interpreter) FullName() string {
+	name := "Python" + i.Version.String()
+	if i.Revision > 0 {
+		name += fmt.Sprintf("_%d", i.Revision)
+	}
+	return name
+}
+
+// Label returns the display name including arch and prefix, e.g.
+// "Python3.11.9 (x86_64, /usr/local)". Interpreters that don't come from
+// Homebrew are labelled with their source as well.
+func (i Interpreter) Label() string {
+	if i.Source != "" && i.Source != sourceHomebrew {
+		return fmt.Sprintf("%s (%s, %s: %s)", i.FullName(), i.Arch, i.Source, i.Prefix)
 	}
-	return "/usr/local/bin"
+	return fmt.Sprintf("%s (%s, %s)", i.FullName(), i.Arch, i.Prefix)
+}
+
+// Matches reports whether the interpreter is the same build as other
+func (i Interpreter) Matches(other Interpreter) bool {
+	return i.Version.Compare(other.Version) == 0 && i.Revision == other.Revision && i.Prefix == other.Prefix
+}
+
+// MinorVersion returns the X.Y part used in executable names, e.g. "3.11"
+func (i Interpreter) MinorVersion() string {
+	return i.Version.MinorString()
 }
 
-func findPythonVersions() ([]string, error) {
-	binDir := getBinDir()
+// Binary returns the path of the named executable for this interpreter
+func (i Interpreter) Binary(name string) string {
+	return filepath.Join(i.BinDir, name)
+}
+
+// Executable returns the path of the versioned python binary, e.g.
+// /opt/homebrew/bin/python3.11
+func (i Interpreter) Executable() string {
+	return i.Binary("python" + i.MinorVersion())
+}
+
+var (
+	// regex to match python3.11, python3.12 or free-threaded python3.13t etc
+	pythonBinRe = regexp.MustCompile(`^python(\d+\.\d+t?)$`)
+	// regex to match Cellar formula directories like python@3.11 or python-freethreading
+	formulaRe = regexp.MustCompile(`^python(?:@\d+\.\d+|-freethreading(?:@\d+\.\d+)?)$`)
+	// regex to match keg directories like 3.11.9 or 3.11.9_1
+	kegRe = regexp.MustCompile(`^([^_]+)(?:_(\d+))?$`)
+)
+
+// findPythonVersions lists the interpreters from every enabled source,
+// sorted by version
+func findPythonVersions() ([]Interpreter, error) {
+	return scanInterpreters(loadConfig())
+}
+
+// scanInterpreters is findPythonVersions for an already loaded config
+func scanInterpreters(config Config) ([]Interpreter, error) {
+	var versions []Interpreter
+	for _, source := range getSources(config) {
+		found, err := source.Discover(config)
+		if err != nil {
+			// Homebrew is the one source BrewPy can't do without
+			if source.Name() == sourceHomebrew {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "%s Failed to scan %s interpreters: %v\n", yellow("Warning:"), source.Name(), err)
+			continue
+		}
+		versions = append(versions, found...)
+	}
+
+	// Keep source and prefix order within a version so the primary prefix's
+	// build comes first
+	sort.SliceStable(versions, func(i, j int) bool {
+		if c := versions[i].Version.Compare(versions[j].Version); c != 0 {
+			return c < 0
+		}
+		return versions[i].Revision < versions[j].Revision
+	})
+
+	return versions, nil
+}
+
+// scanPrefix finds every Python keg in a prefix's Cellar, plus any python3.X
+// in its bin directory that doesn't belong to a keg
+func scanPrefix(prefix string) ([]Interpreter, error) {
+	binDir := filepath.Join(prefix, "bin")
 	files, err := os.ReadDir(binDir)
 	if err != nil {
 		return nil, err
 	}
 
-	// regex to match python3.11 or python3.12 etc
-	pythonRe := regexp.MustCompile(`^python(\d+\.\d+)$`)
+	var versions []Interpreter
+	fromCellar := map[string]bool{}
+
+	formulas, _ := os.ReadDir(filepath.Join(prefix, "Cellar"))
+	for _, formula := range formulas {
+		if !formulaRe.MatchString(formula.Name()) {
+			continue
+		}
 
-	versionMap := map[string]struct{}{}
+		for _, keg := range scanFormula(prefix, formula.Name()) {
+			fromCellar[keg.MinorVersion()] = true
+			versions = append(versions, keg)
+		}
+	}
 
 	for _, file := range files {
 		name := file.Name()
-		matches := pythonRe.FindStringSubmatch(name)
-		if len(matches) == 2 {
-			ver := "Python" + matches[1]
-			versionMap[ver] = struct{}{}
+		matches := pythonBinRe.FindStringSubmatch(name)
+		if len(matches) != 2 || fromCellar[matches[1]] {
+			continue
 		}
+		if version, err := ParseVersion(matches[1]); err == nil {
+			versions = append(versions, newInterpreter(version, prefix, filepath.Join(binDir, name)))
+		}
+	}
+
+	return versions, nil
+}
+
+// scanFormula lists the interpreters in each installed keg of a Python
+// formula and flags the keg its opt link points to. A keg can hold more than
+// one interpreter, e.g. python3.13 and the free-threaded python3.13t.
+func scanFormula(prefix, formula string) []Interpreter {
+	formulaDir := filepath.Join(prefix, "Cellar", formula)
+	entries, err := os.ReadDir(formulaDir)
+	if err != nil {
+		return nil
 	}
 
-	versions := make([]string, 0, len(versionMap))
-	for v := range versionMap {
-		versions = append(versions, v)
+	linkedKeg := ""
+	if resolved, err := filepath.EvalSymlinks(filepath.Join(prefix, "opt", formula)); err == nil {
+		linkedKeg = filepath.Base(resolved)
 	}
-	sort.Strings(versions)
 
-	return versions, nil
+	var kegs []Interpreter
+	for _, entry := range entries {
+		matches := kegRe.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || len(matches) != 3 {
+			continue
+		}
+		kegVersion, err := ParseVersion(matches[1])
+		if err != nil {
+			continue
+		}
+
+		kegDir := filepath.Join(formulaDir, entry.Name())
+		files, err := os.ReadDir(filepath.Join(kegDir, "bin"))
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			exe := file.Name()
+			exeMatches := pythonBinRe.FindStringSubmatch(exe)
+			if len(exeMatches) != 2 {
+				continue
+			}
+			exeVersion, err := ParseVersion(exeMatches[1])
+			if err != nil || exeVersion.Major != kegVersion.Major || exeVersion.Minor != kegVersion.Minor {
+				continue
+			}
+
+			version := kegVersion
+			version.Variant = exeVersion.Variant
+			path := filepath.Join(kegDir, "bin", exe)
+			linked := entry.Name() == linkedKeg
+
+			// The linked keg is reached through the prefix's bin dir, like Homebrew does
+			if linked && linksInto(filepath.Join(prefix, "bin", exe), kegDir) {
+				path = filepath.Join(prefix, "bin", exe)
+			}
+
+			interp := newInterpreter(version, prefix, path)
+			interp.Revision, _ = strconv.Atoi(matches[2])
+			interp.Keg = kegDir
+			interp.Linked = linked
+			kegs = append(kegs, interp)
+		}
+	}
+
+	return kegs
+}
+
+// linksInto reports whether path resolves to a file inside dir
+func linksInto(path, dir string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(resolved, resolvedDir+string(filepath.Separator))
 }
 
-func getCurrentVersion() string {
+// newInterpreter builds an Interpreter for the python binary at path
+func newInterpreter(version Version, prefix, path string) Interpreter {
+	arch := binaryArch(path)
+	if arch == "" {
+		arch = prefixArch(prefix)
+	}
+
+	return Interpreter{
+		Version: version,
+		Prefix:  prefix,
+		Arch:    arch,
+		BinDir:  filepath.Dir(path),
+	}
+}
+
+// preferInterpreter reports whether a should be chosen over b when both match
+// a request: the newer minor series first, then the keg opt points to, then
+// the newest patch. versions is sorted, so ties keep the earlier (primary)
+// prefix.
+func preferInterpreter(a, b Interpreter) bool {
+	if !a.Version.SameMinor(b.Version) {
+		return a.Version.Compare(b.Version) > 0
+	}
+	if a.Linked != b.Linked {
+		return a.Linked
+	}
+	if c := a.Version.Compare(b.Version); c != 0 {
+		return c > 0
+	}
+	return a.Revision > b.Revision
+}
+
+// getCurrentVersion returns the selection recorded by the last `brewpy use`
+func getCurrentVersion() (Interpreter, bool) {
 	config := loadConfig()
-	
+
+	state, ok, err := loadState(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", yellow("Warning:"), err)
+	}
+	if ok {
+		versions, _ := findPythonVersions()
+		return state.Interpreter(versions), true
+	}
+
+	return getLinkedVersion(config)
+}
+
+// getLinkedVersion works out the active version from the python shim's link
+// target, for shims created before the state file existed
+func getLinkedVersion(config Config) (Interpreter, bool) {
 	shimsDir := getShimsDir(config.BrewPyDir)
 	pythonShim := filepath.Join(shimsDir, "python")
 	if _, err := os.Lstat(pythonShim); os.IsNotExist(err) {
-		return ""
+		return Interpreter{}, false
 	}
 
 	target, err := os.Readlink(pythonShim)
 	if err != nil {
-		return ""
+		return Interpreter{}, false
+	}
+
+	versions, _ := findPythonVersions()
+	if version, ok := findByTarget(versions, target); ok {
+		return version, true
+	}
+	return parseLinkTarget(target)
+}
+
+// findByTarget matches a shim's resolved target against the installed
+// interpreters, so the exact patch version behind a bin or opt link is
+// reported even after Homebrew upgraded it
+func findByTarget(versions []Interpreter, target string) (Interpreter, bool) {
+	resolved, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return Interpreter{}, false
+	}
+	for _, v := range versions {
+		candidate, err := filepath.EvalSymlinks(v.Executable())
+		if err == nil && candidate == resolved {
+			return v, true
+		}
 	}
+	return Interpreter{}, false
+}
+
+var (
+	// regex to match a Cellar link target like .../Cellar/python@3.11/3.11.9_1/bin/python3.11
+	cellarTargetRe = regexp.MustCompile(`^(.*)/Cellar/(python[^/]*)/([^/]+)/bin/python(\d+\.\d+t?)$`)
+	// regex to match an opt link target like .../opt/python@3.11/bin/python3.11
+	optTargetRe = regexp.MustCompile(`^(.*)/opt/(python[^/]*)/bin/python(\d+\.\d+t?)$`)
+	// regex to match a bin link target like /opt/homebrew/bin/python3.11
+	binTargetRe = regexp.MustCompile(`^(.*)/bin/python(\d+\.\d+t?)$`)
+)
 
-	// Extract version from target path like /opt/homebrew/bin/python3.11
-	base := filepath.Base(target)
-	re := regexp.MustCompile(`python(\d+\.\d+)`)
-	matches := re.FindStringSubmatch(base)
-	if len(matches) == 2 {
-		return "Python" + matches[1]
+// parseLinkTarget works out the version from a python shim's target path in
+// any of the link-target layouts, for when it no longer matches an install
+func parseLinkTarget(target string) (Interpreter, bool) {
+	if m := cellarTargetRe.FindStringSubmatch(target); m != nil {
+		// A keg directory that isn't a version falls through to the other layouts
+		if keg := kegRe.FindStringSubmatch(m[3]); keg != nil {
+			if version, err := ParseVersion(keg[1]); err == nil {
+				exe, _ := ParseVersion(m[4])
+				version.Variant = exe.Variant
+				interp := newInterpreter(version, m[1], target)
+				interp.Revision, _ = strconv.Atoi(keg[2])
+				interp.Keg = filepath.Join(m[1], "Cellar", m[2], m[3])
+				return interp, true
+			}
+		}
 	}
 
-	return ""
-} 
\ No newline at end of file
+	if m := optTargetRe.FindStringSubmatch(target); m != nil {
+		if version, err := ParseVersion(m[3]); err == nil {
+			return newInterpreter(version, m[1], target), true
+		}
+	}
+
+	if m := binTargetRe.FindStringSubmatch(target); m != nil {
+		if version, err := ParseVersion(m[2]); err == nil {
+			return newInterpreter(version, m[1], target), true
+		}
+	}
+
+	return Interpreter{}, false
+}